@@ -38,6 +38,163 @@ func (s *AlternateServer) GetFrom(m *Message) error {
 	return a.getAs(m, AttrAlternateServer)
 }
 
+// ResponseOrigin represents RESPONSE-ORIGIN attribute.
+//
+// RFC 5780 Section 7.3
+type ResponseOrigin struct {
+	IP   net.IP
+	Port int
+}
+
+// AddTo adds RESPONSE-ORIGIN attribute to message.
+func (o *ResponseOrigin) AddTo(m *Message) error {
+	a := (*MappedAddress)(o)
+	return a.addAs(m, AttrResponseOrigin)
+}
+
+// GetFrom decodes RESPONSE-ORIGIN from message.
+func (o *ResponseOrigin) GetFrom(m *Message) error {
+	a := (*MappedAddress)(o)
+	return a.getAs(m, AttrResponseOrigin)
+}
+
+func (o ResponseOrigin) String() string {
+	return MappedAddress(o).String()
+}
+
+// OtherAddress represents OTHER-ADDRESS attribute.
+//
+// RFC 5780 Section 7.4
+type OtherAddress struct {
+	IP   net.IP
+	Port int
+}
+
+// AddTo adds OTHER-ADDRESS attribute to message.
+func (o *OtherAddress) AddTo(m *Message) error {
+	a := (*MappedAddress)(o)
+	return a.addAs(m, AttrOtherAddress)
+}
+
+// GetFrom decodes OTHER-ADDRESS from message.
+func (o *OtherAddress) GetFrom(m *Message) error {
+	a := (*MappedAddress)(o)
+	return a.getAs(m, AttrOtherAddress)
+}
+
+func (o OtherAddress) String() string {
+	return MappedAddress(o).String()
+}
+
+// ResponseAddress represents RESPONSE-ADDRESS attribute.
+//
+// The RESPONSE-ADDRESS attribute indicates where the response to
+// the Binding Request should be sent.
+//
+// RFC 3489 Section 11.2.2
+type ResponseAddress struct {
+	IP   net.IP
+	Port int
+}
+
+// AddTo adds RESPONSE-ADDRESS attribute to message.
+func (r *ResponseAddress) AddTo(m *Message) error {
+	a := (*MappedAddress)(r)
+	return a.addAs(m, AttrResponseAddress)
+}
+
+// GetFrom decodes RESPONSE-ADDRESS from message.
+func (r *ResponseAddress) GetFrom(m *Message) error {
+	a := (*MappedAddress)(r)
+	return a.getAs(m, AttrResponseAddress)
+}
+
+func (r ResponseAddress) String() string {
+	return MappedAddress(r).String()
+}
+
+// SourceAddress represents SOURCE-ADDRESS attribute.
+//
+// The SOURCE-ADDRESS attribute indicates the source IP address and
+// port that the server is sending the response from.
+//
+// RFC 3489 Section 11.2.5
+type SourceAddress struct {
+	IP   net.IP
+	Port int
+}
+
+// AddTo adds SOURCE-ADDRESS attribute to message.
+func (s *SourceAddress) AddTo(m *Message) error {
+	a := (*MappedAddress)(s)
+	return a.addAs(m, AttrSourceAddress)
+}
+
+// GetFrom decodes SOURCE-ADDRESS from message.
+func (s *SourceAddress) GetFrom(m *Message) error {
+	a := (*MappedAddress)(s)
+	return a.getAs(m, AttrSourceAddress)
+}
+
+func (s SourceAddress) String() string {
+	return MappedAddress(s).String()
+}
+
+// ChangedAddress represents CHANGED-ADDRESS attribute.
+//
+// The CHANGED-ADDRESS attribute indicates the IP address and port
+// where responses would have been sent from if the "change IP" and
+// "change port" flags had been set in the CHANGE-REQUEST attribute.
+//
+// RFC 3489 Section 11.2.3
+type ChangedAddress struct {
+	IP   net.IP
+	Port int
+}
+
+// AddTo adds CHANGED-ADDRESS attribute to message.
+func (c *ChangedAddress) AddTo(m *Message) error {
+	a := (*MappedAddress)(c)
+	return a.addAs(m, AttrChangedAddress)
+}
+
+// GetFrom decodes CHANGED-ADDRESS from message.
+func (c *ChangedAddress) GetFrom(m *Message) error {
+	a := (*MappedAddress)(c)
+	return a.getAs(m, AttrChangedAddress)
+}
+
+func (c ChangedAddress) String() string {
+	return MappedAddress(c).String()
+}
+
+// ReflectedFrom represents REFLECTED-FROM attribute.
+//
+// The REFLECTED-FROM attribute contains the source address of the
+// request that caused the response to be sent to RESPONSE-ADDRESS.
+//
+// RFC 3489 Section 11.2.11
+type ReflectedFrom struct {
+	IP   net.IP
+	Port int
+}
+
+// AddTo adds REFLECTED-FROM attribute to message.
+func (f *ReflectedFrom) AddTo(m *Message) error {
+	a := (*MappedAddress)(f)
+	return a.addAs(m, AttrReflectedFrom)
+}
+
+// GetFrom decodes REFLECTED-FROM from message.
+func (f *ReflectedFrom) GetFrom(m *Message) error {
+	a := (*MappedAddress)(f)
+	return a.getAs(m, AttrReflectedFrom)
+}
+
+func (f ReflectedFrom) String() string {
+	return MappedAddress(f).String()
+}
+
 func (a MappedAddress) String() string {
 	return net.JoinHostPort(a.IP.String(), strconv.Itoa(a.Port))
 }
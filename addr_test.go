@@ -115,6 +115,54 @@ func TestAlternateServer(t *testing.T) {
 	})
 }
 
+func TestResponseOrigin(t *testing.T) {
+	m := new(Message)
+	addr := &ResponseOrigin{
+		IP:   net.ParseIP("122.12.34.5"),
+		Port: 5412,
+	}
+	if addr.String() != "122.12.34.5:5412" {
+		t.Error("bad string", addr)
+	}
+	if err := addr.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	got := new(ResponseOrigin)
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if !got.IP.Equal(addr.IP) || got.Port != addr.Port {
+		t.Error("got bad address: ", got)
+	}
+	if err := new(OtherAddress).GetFrom(m); err != ErrAttributeNotFound {
+		t.Error("should be not found: ", err)
+	}
+}
+
+func TestOtherAddress(t *testing.T) {
+	m := new(Message)
+	addr := &OtherAddress{
+		IP:   net.ParseIP("2001:db8::1"),
+		Port: 3479,
+	}
+	if addr.String() != "[2001:db8::1]:3479" {
+		t.Error("bad string", addr)
+	}
+	if err := addr.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	got := new(OtherAddress)
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if !got.IP.Equal(addr.IP) || got.Port != addr.Port {
+		t.Error("got bad address: ", got)
+	}
+	if err := new(ResponseOrigin).GetFrom(m); err != ErrAttributeNotFound {
+		t.Error("should be not found: ", err)
+	}
+}
+
 func BenchmarkMappedAddress_AddTo(b *testing.B) {
 	m := new(Message)
 	b.ReportAllocs()
@@ -144,3 +192,39 @@ func BenchmarkAlternateServer_AddTo(b *testing.B) {
 		m.Reset()
 	}
 }
+
+func TestClassicAddresses(t *testing.T) {
+	ip := net.ParseIP("122.12.34.5")
+	for _, tc := range []struct {
+		name string
+		in   Setter
+		out  interface {
+			Getter
+			String() string
+		}
+	}{
+		{"ResponseAddress", &ResponseAddress{IP: ip, Port: 5412}, new(ResponseAddress)},
+		{"SourceAddress", &SourceAddress{IP: ip, Port: 5412}, new(SourceAddress)},
+		{"ChangedAddress", &ChangedAddress{IP: ip, Port: 5412}, new(ChangedAddress)},
+		{"ReflectedFrom", &ReflectedFrom{IP: ip, Port: 5412}, new(ReflectedFrom)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(Message)
+			if err := tc.out.GetFrom(m); err != ErrAttributeNotFound {
+				t.Error("should be not found: ", err)
+			}
+			if err := tc.in.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Attributes) != 1 || m.Attributes[0].Type == AttrMappedAddress {
+				t.Fatal("bad attributes: ", m.Attributes)
+			}
+			if err := tc.out.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if tc.out.String() != "122.12.34.5:5412" {
+				t.Error("got bad address: ", tc.out)
+			}
+		})
+	}
+}
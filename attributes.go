@@ -84,9 +84,43 @@ const (
 	AttrRequestedAddressFamily AttrType = 0x0017 // REQUESTED-ADDRESS-FAMILY
 )
 
+// Attributes from RFC 8656 TURN.
+const (
+	AttrAdditionalAddressFamily AttrType = 0x8000 // ADDITIONAL-ADDRESS-FAMILY
+	AttrAddressErrorCode        AttrType = 0x8001 // ADDRESS-ERROR-CODE
+	AttrICMP                    AttrType = 0x8004 // ICMP
+)
+
+// Attributes from RFC 7635 Third-Party Authorization.
+const (
+	AttrAccessToken             AttrType = 0x001B // ACCESS-TOKEN
+	AttrThirdPartyAuthorization AttrType = 0x802E // THIRD-PARTY-AUTHORIZATION
+)
+
+// Attributes from RFC 5780 NAT Behavior Discovery.
+const (
+	AttrChangeRequest  AttrType = 0x0003 // CHANGE-REQUEST
+	AttrPadding        AttrType = 0x0026 // PADDING
+	AttrResponsePort   AttrType = 0x0027 // RESPONSE-PORT
+	AttrResponseOrigin AttrType = 0x802B // RESPONSE-ORIGIN
+	AttrOtherAddress   AttrType = 0x802C // OTHER-ADDRESS
+)
+
+// Attributes from RFC 3489 Classic STUN, deprecated by RFC 5389.
+//
+// The AttrXORMappedAddressOld is XOR-MAPPED-ADDRESS code point from
+// pre-RFC 5389 drafts, use XORMappedAddress.AddToAs and GetFromAs with it.
+const (
+	AttrResponseAddress     AttrType = 0x0002 // RESPONSE-ADDRESS
+	AttrSourceAddress       AttrType = 0x0004 // SOURCE-ADDRESS
+	AttrChangedAddress      AttrType = 0x0005 // CHANGED-ADDRESS
+	AttrReflectedFrom       AttrType = 0x000B // REFLECTED-FROM
+	AttrXORMappedAddressOld AttrType = 0x8020 // XOR-MAPPED-ADDRESS-OLD
+)
+
 // Attributes from An Origin Attribute for the STUN Protocol.
 const (
-	AttrOrigin AttrType = 0x802F
+	AttrOrigin AttrType = 0x802F // ORIGIN
 )
 
 // Attributes from RFC 8489 STUN.
@@ -104,37 +138,53 @@ func (t AttrType) Value() uint16 {
 }
 
 var attrNames = map[AttrType]string{
-	AttrMappedAddress:          "MAPPED-ADDRESS",
-	AttrUsername:               "USERNAME",
-	AttrErrorCode:              "ERROR-CODE",
-	AttrMessageIntegrity:       "MESSAGE-INTEGRITY",
-	AttrUnknownAttributes:      "UNKNOWN-ATTRIBUTES",
-	AttrRealm:                  "REALM",
-	AttrNonce:                  "NONCE",
-	AttrXORMappedAddress:       "XOR-MAPPED-ADDRESS",
-	AttrSoftware:               "SOFTWARE",
-	AttrAlternateServer:        "ALTERNATE-SERVER",
-	AttrFingerprint:            "FINGERPRINT",
-	AttrPriority:               "PRIORITY",
-	AttrUseCandidate:           "USE-CANDIDATE",
-	AttrICEControlled:          "ICE-CONTROLLED",
-	AttrICEControlling:         "ICE-CONTROLLING",
-	AttrChannelNumber:          "CHANNEL-NUMBER",
-	AttrLifetime:               "LIFETIME",
-	AttrXORPeerAddress:         "XOR-PEER-ADDRESS",
-	AttrData:                   "DATA",
-	AttrXORRelayedAddress:      "XOR-RELAYED-ADDRESS",
-	AttrEvenPort:               "EVEN-PORT",
-	AttrRequestedTransport:     "REQUESTED-TRANSPORT",
-	AttrDontFragment:           "DONT-FRAGMENT",
-	AttrReservationToken:       "RESERVATION-TOKEN",
-	AttrConnectionID:           "CONNECTION-ID",
-	AttrRequestedAddressFamily: "REQUESTED-ADDRESS-FAMILY",
-	AttrMessageIntegritySHA256: "MESSAGE-INTEGRITY-SHA256",
-	AttrPasswordAlgorithm:      "PASSWORD-ALGORITHM",
-	AttrUserhash:               "USERHASH",
-	AttrPasswordAlgorithms:     "PASSWORD-ALGORITHMS",
-	AttrAlternateDomain:        "ALTERNATE-DOMAIN",
+	AttrMappedAddress:           "MAPPED-ADDRESS",
+	AttrUsername:                "USERNAME",
+	AttrErrorCode:               "ERROR-CODE",
+	AttrMessageIntegrity:        "MESSAGE-INTEGRITY",
+	AttrUnknownAttributes:       "UNKNOWN-ATTRIBUTES",
+	AttrRealm:                   "REALM",
+	AttrNonce:                   "NONCE",
+	AttrXORMappedAddress:        "XOR-MAPPED-ADDRESS",
+	AttrSoftware:                "SOFTWARE",
+	AttrAlternateServer:         "ALTERNATE-SERVER",
+	AttrFingerprint:             "FINGERPRINT",
+	AttrPriority:                "PRIORITY",
+	AttrUseCandidate:            "USE-CANDIDATE",
+	AttrICEControlled:           "ICE-CONTROLLED",
+	AttrICEControlling:          "ICE-CONTROLLING",
+	AttrChannelNumber:           "CHANNEL-NUMBER",
+	AttrLifetime:                "LIFETIME",
+	AttrXORPeerAddress:          "XOR-PEER-ADDRESS",
+	AttrData:                    "DATA",
+	AttrXORRelayedAddress:       "XOR-RELAYED-ADDRESS",
+	AttrEvenPort:                "EVEN-PORT",
+	AttrRequestedTransport:      "REQUESTED-TRANSPORT",
+	AttrDontFragment:            "DONT-FRAGMENT",
+	AttrReservationToken:        "RESERVATION-TOKEN",
+	AttrConnectionID:            "CONNECTION-ID",
+	AttrRequestedAddressFamily:  "REQUESTED-ADDRESS-FAMILY",
+	AttrAdditionalAddressFamily: "ADDITIONAL-ADDRESS-FAMILY",
+	AttrAddressErrorCode:        "ADDRESS-ERROR-CODE",
+	AttrICMP:                    "ICMP",
+	AttrAccessToken:             "ACCESS-TOKEN",
+	AttrThirdPartyAuthorization: "THIRD-PARTY-AUTHORIZATION",
+	AttrMessageIntegritySHA256:  "MESSAGE-INTEGRITY-SHA256",
+	AttrPasswordAlgorithm:       "PASSWORD-ALGORITHM",
+	AttrUserhash:                "USERHASH",
+	AttrPasswordAlgorithms:      "PASSWORD-ALGORITHMS",
+	AttrAlternateDomain:         "ALTERNATE-DOMAIN",
+	AttrChangeRequest:           "CHANGE-REQUEST",
+	AttrPadding:                 "PADDING",
+	AttrResponsePort:            "RESPONSE-PORT",
+	AttrResponseOrigin:          "RESPONSE-ORIGIN",
+	AttrOrigin:                  "ORIGIN",
+	AttrOtherAddress:            "OTHER-ADDRESS",
+	AttrResponseAddress:         "RESPONSE-ADDRESS",
+	AttrSourceAddress:           "SOURCE-ADDRESS",
+	AttrChangedAddress:          "CHANGED-ADDRESS",
+	AttrReflectedFrom:           "REFLECTED-FROM",
+	AttrXORMappedAddressOld:     "XOR-MAPPED-ADDRESS-OLD",
 }
 
 func (t AttrType) String() string {
@@ -0,0 +1,216 @@
+package stun
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ChannelData represents The ChannelData Message.
+//
+// The ChannelData message is used to carry application data between the
+// client and the server over bound channel, it has 4-byte header with
+// channel number and length of application data.
+//
+// RFC 5766 Section 11.4
+type ChannelData struct {
+	Data   []byte // can be subslice of Raw
+	Length int    // ignored while encoding, len(Data) is used
+	Number ChannelNumber
+	Raw    []byte
+}
+
+const channelDataHeaderSize = 4
+
+// ErrBadChannelDataLength means that length field of ChannelData is bigger
+// than actual data length.
+var ErrBadChannelDataLength = errors.New("channelData length != len(Data)")
+
+// IsChannelData returns true if b looks like ChannelData message. Useful
+// for demultiplexing ChannelData, STUN messages and application data.
+// IsChannelData does not guarantee that decoding will be successful.
+//
+// RFC 5766 Section 11
+func IsChannelData(b []byte) bool {
+	if len(b) < channelDataHeaderSize {
+		return false
+	}
+	if !ChannelNumber(bin.Uint16(b[0:2])).Valid() {
+		return false
+	}
+	// Data can be followed by padding.
+	return int(bin.Uint16(b[2:4])) <= len(b)-channelDataHeaderSize
+}
+
+// Equal returns true if b == c.
+func (c *ChannelData) Equal(b *ChannelData) bool {
+	if c == nil && b == nil {
+		return true
+	}
+	if c == nil || b == nil {
+		return false
+	}
+	if c.Number != b.Number {
+		return false
+	}
+	if len(c.Data) != len(b.Data) {
+		return false
+	}
+	return bytes.Equal(c.Data, b.Data)
+}
+
+// Reset resets Length, Data and Raw length.
+func (c *ChannelData) Reset() {
+	c.Raw = c.Raw[:0]
+	c.Length = 0
+	c.Data = c.Data[:0]
+}
+
+// grow ensures that internal buffer has n length.
+func (c *ChannelData) grow(n int) {
+	if len(c.Raw) >= n {
+		return
+	}
+	if cap(c.Raw) >= n {
+		c.Raw = c.Raw[:n]
+		return
+	}
+	c.Raw = append(c.Raw, make([]byte, n-len(c.Raw))...)
+}
+
+// WriteHeader writes channel number and length.
+func (c *ChannelData) WriteHeader() {
+	c.grow(channelDataHeaderSize)
+	_ = c.Raw[:channelDataHeaderSize] // early bounds check to guarantee safety of writes below
+	bin.PutUint16(c.Raw[0:2], uint16(c.Number))
+	bin.PutUint16(c.Raw[2:4], uint16(len(c.Data)))
+}
+
+// Encode encodes ChannelData message to c.Raw, reusing it if possible.
+func (c *ChannelData) Encode() {
+	c.encode(channelDataHeaderSize + len(c.Data))
+}
+
+// EncodePadded encodes ChannelData message to c.Raw with padding to
+// multiple of 4 bytes, as required over stream transports like TCP.
+//
+// RFC 5766 Section 11.5
+func (c *ChannelData) EncodePadded() {
+	c.encode(channelDataHeaderSize + nearestPaddedValueLength(len(c.Data)))
+}
+
+func (c *ChannelData) encode(size int) {
+	c.Raw = c.Raw[:0]
+	c.grow(size)
+	c.WriteHeader()
+	n := copy(c.Raw[channelDataHeaderSize:], c.Data)
+	for i := channelDataHeaderSize + n; i < size; i++ {
+		c.Raw[i] = 0
+	}
+	c.Length = len(c.Data)
+}
+
+// Decode decodes c.Raw into c. The padding after data is ignored.
+func (c *ChannelData) Decode() error {
+	buf := c.Raw
+	if len(buf) < channelDataHeaderSize {
+		return io.ErrUnexpectedEOF
+	}
+	num := ChannelNumber(bin.Uint16(buf[0:2]))
+	if !num.Valid() {
+		return ErrInvalidChannelNumber
+	}
+	c.Number = num
+	c.Length = int(bin.Uint16(buf[2:4]))
+	if c.Length > len(buf)-channelDataHeaderSize {
+		return ErrBadChannelDataLength
+	}
+	c.Data = buf[channelDataHeaderSize : channelDataHeaderSize+c.Length]
+	return nil
+}
+
+// maxFrameSize is maximum size of STUN message, which is bigger than
+// maximum size of padded ChannelData.
+const maxFrameSize = messageHeaderSize + 0xFFFF
+
+// StreamReader reads STUN messages and ChannelData messages from stream
+// transport like TCP, where frame boundaries are determined by header
+// length and ChannelData messages are padded to multiple of 4 bytes.
+//
+// RFC 5766 Section 11.5
+type StreamReader struct {
+	r     io.Reader
+	buf   []byte
+	start int // start of unread data in buf
+	end   int // end of unread data in buf
+}
+
+// NewStreamReader returns new StreamReader for r.
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{
+		r:   r,
+		buf: make([]byte, maxFrameSize),
+	}
+}
+
+// ErrUnknownFrame means that frame is neither STUN message nor ChannelData.
+var ErrUnknownFrame = errors.New("frame is not STUN message or ChannelData")
+
+// fill ensures that at least n unread bytes are buffered.
+func (s *StreamReader) fill(n int) error {
+	if s.end-s.start >= n {
+		return nil
+	}
+	if s.start+n > len(s.buf) {
+		s.end = copy(s.buf, s.buf[s.start:s.end])
+		s.start = 0
+	}
+	for s.end-s.start < n {
+		read, err := s.r.Read(s.buf[s.end:])
+		s.end += read
+		if s.end-s.start >= n {
+			return nil
+		}
+		if err == io.EOF && s.end > s.start {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Next reads next frame from stream, returning bytes of STUN message or
+// ChannelData message without padding, that can be decoded via Message or
+// ChannelData. Returned slice is valid until next call.
+//
+// Returns io.EOF if stream ended on frame boundary or ErrUnknownFrame if
+// stream is not in sync.
+func (s *StreamReader) Next() ([]byte, error) {
+	if err := s.fill(channelDataHeaderSize); err != nil {
+		return nil, err
+	}
+	var (
+		header = s.buf[s.start : s.start+channelDataHeaderSize]
+		length = int(bin.Uint16(header[2:4]))
+		size   int // size of frame
+		padded int // size of frame with padding
+	)
+	switch header[0] >> 6 {
+	case 0: // STUN message, first two bits are zeroes
+		size = messageHeaderSize + length
+		padded = size
+	case 1: // ChannelData, channel number is in [0x4000, 0x7FFF]
+		size = channelDataHeaderSize + length
+		padded = channelDataHeaderSize + nearestPaddedValueLength(length)
+	default:
+		return nil, ErrUnknownFrame
+	}
+	if err := s.fill(padded); err != nil {
+		return nil, err
+	}
+	frame := s.buf[s.start : s.start+size]
+	s.start += padded
+	return frame, nil
+}
@@ -0,0 +1,203 @@
+package stun
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestChannelData_Encode(t *testing.T) {
+	d := &ChannelData{
+		Data:   []byte{1, 2, 3, 4, 5},
+		Number: MinChannelNumber + 1,
+	}
+	d.Encode()
+	if !bytes.Equal(d.Raw, []byte{0x40, 0x01, 0, 5, 1, 2, 3, 4, 5}) {
+		t.Errorf("unexpected raw %x", d.Raw)
+	}
+	if !IsChannelData(d.Raw) {
+		t.Error("should be channel data")
+	}
+	b := &ChannelData{Raw: append([]byte{}, d.Raw...)}
+	if err := b.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(d) {
+		t.Error("not equal")
+	}
+	if b.Length != 5 {
+		t.Errorf("unexpected length %d", b.Length)
+	}
+	t.Run("Padded", func(t *testing.T) {
+		d.EncodePadded()
+		if !bytes.Equal(d.Raw, []byte{0x40, 0x01, 0, 5, 1, 2, 3, 4, 5, 0, 0, 0}) {
+			t.Errorf("unexpected raw %x", d.Raw)
+		}
+		b := &ChannelData{Raw: d.Raw}
+		if err := b.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if !b.Equal(d) {
+			t.Error("not equal")
+		}
+	})
+	t.Run("Reuse", func(t *testing.T) {
+		// Data is subslice of Raw after decoding.
+		b.Number++
+		b.Encode()
+		if !bytes.Equal(b.Raw, []byte{0x40, 0x02, 0, 5, 1, 2, 3, 4, 5}) {
+			t.Errorf("unexpected raw %x", b.Raw)
+		}
+		b.Reset()
+		if len(b.Raw) != 0 || len(b.Data) != 0 || b.Length != 0 {
+			t.Error("not reset")
+		}
+	})
+}
+
+func TestChannelData_Decode(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		err  error
+	}{
+		{"Empty", nil, io.ErrUnexpectedEOF},
+		{"Short", []byte{0x40, 0x00, 0}, io.ErrUnexpectedEOF},
+		{"BadNumber", []byte{0x80, 0x00, 0, 0}, ErrInvalidChannelNumber},
+		{"BadLength", []byte{0x40, 0x00, 0, 5, 1, 2, 3, 4}, ErrBadChannelDataLength},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &ChannelData{Raw: tc.raw}
+			if err := d.Decode(); err != tc.err {
+				t.Errorf("unexpected error %v", err)
+			}
+			if IsChannelData(tc.raw) {
+				t.Error("should not be channel data")
+			}
+		})
+	}
+	if (*ChannelData)(nil).Equal(&ChannelData{}) {
+		t.Error("nil should not be equal")
+	}
+	if !(*ChannelData)(nil).Equal(nil) {
+		t.Error("nil should be equal")
+	}
+}
+
+func TestIsChannelData(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, Fingerprint)
+	if IsChannelData(m.Raw) {
+		t.Error("STUN message should not be channel data")
+	}
+	d := &ChannelData{Number: MaxChannelNumber, Data: []byte{1}}
+	d.Encode()
+	if IsMessage(d.Raw) || !IsChannelData(d.Raw) {
+		t.Error("should be channel data")
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	var (
+		stream = new(bytes.Buffer)
+		m      = MustBuild(TransactionID, BindingRequest, NewSoftware("test"), Fingerprint)
+		d      = &ChannelData{Number: MinChannelNumber, Data: []byte{1, 2, 3, 4, 5}}
+		empty  = &ChannelData{Number: MaxChannelNumber}
+	)
+	d.EncodePadded()
+	empty.EncodePadded()
+	for _, b := range [][]byte{m.Raw, d.Raw, empty.Raw, m.Raw, d.Raw} {
+		stream.Write(b)
+	}
+	// Reading by one byte to check buffering.
+	r := NewStreamReader(iotest.OneByteReader(stream))
+	for i, expected := range []interface{}{m, d, empty, m, d} {
+		frame, err := r.Next()
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		switch v := expected.(type) {
+		case *Message:
+			got := &Message{Raw: frame}
+			if err = got.Decode(); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(v) {
+				t.Errorf("%d: message not equal", i)
+			}
+		case *ChannelData:
+			if !IsChannelData(frame) {
+				t.Fatalf("%d: should be channel data", i)
+			}
+			got := &ChannelData{Raw: frame}
+			if err = got.Decode(); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(v) || len(frame) != channelDataHeaderSize+len(v.Data) {
+				t.Errorf("%d: channel data not equal", i)
+			}
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("unexpected error %v", err)
+	}
+	t.Run("UnexpectedEOF", func(t *testing.T) {
+		r := NewStreamReader(bytes.NewReader(d.Raw[:len(d.Raw)-1]))
+		if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("UnknownFrame", func(t *testing.T) {
+		r := NewStreamReader(bytes.NewReader([]byte{0x80, 0, 0, 0}))
+		if _, err := r.Next(); err != ErrUnknownFrame {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Big", func(t *testing.T) {
+		big := &ChannelData{Number: MinChannelNumber, Data: make([]byte, 0xFFFF)}
+		big.EncodePadded()
+		r := NewStreamReader(io.MultiReader(bytes.NewReader(d.Raw), bytes.NewReader(big.Raw)))
+		for _, expected := range []*ChannelData{d, big} {
+			frame, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &ChannelData{Raw: frame}
+			if err = got.Decode(); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(expected) {
+				t.Error("not equal")
+			}
+		}
+	})
+}
+
+func BenchmarkChannelData_Encode(b *testing.B) {
+	b.ReportAllocs()
+	d := &ChannelData{
+		Data:   make([]byte, 100),
+		Number: MinChannelNumber,
+	}
+	for i := 0; i < b.N; i++ {
+		d.Encode()
+	}
+}
+
+func BenchmarkChannelData_Decode(b *testing.B) {
+	b.ReportAllocs()
+	d := &ChannelData{
+		Data:   make([]byte, 100),
+		Number: MinChannelNumber,
+	}
+	d.Encode()
+	buf := make([]byte, len(d.Raw))
+	copy(buf, d.Raw)
+	for i := 0; i < b.N; i++ {
+		d.Reset()
+		d.Raw = buf
+		if err := d.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -43,3 +43,9 @@ func CheckOverflow(_ AttrType, got, max int) error {
 func IsAttrSizeOverflow(err error) bool {
 	return err == ErrAttributeSizeOverflow
 }
+
+// IsIntegrityMismatch returns true if error means that MESSAGE-INTEGRITY
+// value is invalid.
+func IsIntegrityMismatch(err error) bool {
+	return err == ErrIntegrityMismatch
+}
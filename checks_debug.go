@@ -20,9 +20,10 @@ func checkHMAC(got, expected []byte) error {
 	if hmac.Equal(got, expected) {
 		return nil
 	}
+	// Copying values, because they can be reused by caller.
 	return &IntegrityErr{
-		Expected: expected,
-		Actual:   got,
+		Expected: append([]byte{}, expected...),
+		Actual:   append([]byte{}, got...),
 	}
 }
 
@@ -59,3 +60,10 @@ func IsAttrSizeOverflow(err error) bool {
 	_, ok := err.(*AttrOverflowErr)
 	return ok
 }
+
+// IsIntegrityMismatch returns true if error means that MESSAGE-INTEGRITY
+// value is invalid.
+func IsIntegrityMismatch(err error) bool {
+	_, ok := err.(*IntegrityErr)
+	return ok || err == ErrIntegrityMismatch
+}
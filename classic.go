@@ -0,0 +1,89 @@
+package stun
+
+import "crypto/rand"
+
+// ClassicTransactionIDSize is length of RFC 3489 transaction id in bytes.
+//
+// Classic STUN has no magic cookie, so first 4 bytes of transaction id
+// are placed instead of it and the rest 12 bytes are Message.TransactionID.
+const ClassicTransactionIDSize = 16 // 128 bit
+
+// IsClassicMessage returns true if b looks like RFC 3489 message, that is
+// message without magic cookie. Classic messages are hard to distinguish
+// from other protocols, so only first two bits and message length are
+// checked. IsClassicMessage does not guarantee that decoding will be
+// successful.
+func IsClassicMessage(b []byte) bool {
+	if len(b) < messageHeaderSize || IsMessage(b) {
+		return false
+	}
+	if b[0]&0xC0 != 0 {
+		// First two bits of STUN message are zeroes.
+		return false
+	}
+	size := int(bin.Uint16(b[2:4]))
+	return size%padding == 0 && len(b) == messageHeaderSize+size
+}
+
+// DecodeClassic decodes Message from data to m like Decode, but also
+// accepts RFC 3489 messages that have no magic cookie.
+func DecodeClassic(data []byte, m *Message) error {
+	if m == nil {
+		return ErrDecodeToNil
+	}
+	m.Raw = append(m.Raw[:0], data...)
+	return m.DecodeClassic()
+}
+
+// DecodeClassic decodes m.Raw into m like Decode, but does not require
+// magic cookie, so classic RFC 3489 messages can be decoded. Use IsClassic
+// to check whether decoded message is classic one.
+func (m *Message) DecodeClassic() error {
+	return m.decode(ClassicDecoding)
+}
+
+// IsClassic reports whether m.Raw contains RFC 3489 message header, i.e.
+// there is no magic cookie.
+func (m *Message) IsClassic() bool {
+	return len(m.Raw) >= messageHeaderSize && bin.Uint32(m.Raw[4:8]) != magicCookie
+}
+
+// ClassicTransactionID is 128-bit transaction id of RFC 3489 message.
+//
+// Use as Setter to encode classic message, the magic cookie is replaced
+// by first 4 bytes of id. Should be added after the message header is
+// written, e.g. by Build, and m.Encode or m.WriteHeader calls will restore
+// the magic cookie.
+type ClassicTransactionID [ClassicTransactionIDSize]byte
+
+// NewClassicTransactionID returns new random classic transaction ID using
+// crypto/rand as source. The first 4 bytes are never equal to magic cookie.
+func NewClassicTransactionID() (id ClassicTransactionID) {
+	for {
+		readFullOrPanic(rand.Reader, id[:])
+		if bin.Uint32(id[0:4]) != magicCookie {
+			return id
+		}
+	}
+}
+
+// AddTo sets transaction id of m to id, writing first 4 bytes instead
+// of magic cookie.
+func (id ClassicTransactionID) AddTo(m *Message) error {
+	m.grow(messageHeaderSize)
+	copy(m.TransactionID[:], id[4:])
+	m.WriteTransactionID()
+	copy(m.Raw[4:8], id[:4])
+	return nil
+}
+
+// GetFrom decodes classic transaction id from m. For message with magic
+// cookie, the magic cookie will be first 4 bytes of id.
+func (id *ClassicTransactionID) GetFrom(m *Message) error {
+	if len(m.Raw) < messageHeaderSize {
+		return ErrUnexpectedHeaderEOF
+	}
+	copy(id[:4], m.Raw[4:8])
+	copy(id[4:], m.TransactionID[:])
+	return nil
+}
@@ -0,0 +1,126 @@
+package stun
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDecodeClassic(t *testing.T) {
+	id := NewClassicTransactionID()
+	req := MustBuild(BindingRequest, id, &ChangeRequest{ChangePort: true})
+	if !req.IsClassic() {
+		t.Fatal("should be classic")
+	}
+	if IsMessage(req.Raw) {
+		t.Error("should not be message")
+	}
+	if !IsClassicMessage(req.Raw) {
+		t.Error("should be classic message")
+	}
+	if err, ok := Decode(req.Raw, new(Message)).(*DecodeErr); !ok || !err.IsInvalidCookie() {
+		t.Errorf("unexpected error %v", err)
+	}
+	decoded := new(Message)
+	if err := DecodeClassic(req.Raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.IsClassic() || decoded.Type != BindingRequest {
+		t.Error("bad decoded message", decoded)
+	}
+	var gotID ClassicTransactionID
+	if err := gotID.GetFrom(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if gotID != id {
+		t.Error("transaction id mismatch")
+	}
+	var change ChangeRequest
+	if err := change.GetFrom(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !change.ChangePort || change.ChangeIP {
+		t.Error("bad change request", change)
+	}
+	t.Run("Response", func(t *testing.T) {
+		addr := &MappedAddress{IP: net.IPv4(1, 2, 3, 4), Port: 3478}
+		res := MustBuild(decoded, BindingSuccess, addr)
+		if !res.IsClassic() {
+			t.Error("response should be classic")
+		}
+		var resID ClassicTransactionID
+		if err := resID.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if resID != id {
+			t.Error("response transaction id mismatch")
+		}
+	})
+	t.Run("Nil", func(t *testing.T) {
+		if err := DecodeClassic(req.Raw, nil); err != ErrDecodeToNil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Short", func(t *testing.T) {
+		if err := new(ClassicTransactionID).GetFrom(new(Message)); err != ErrUnexpectedHeaderEOF {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Modern", func(t *testing.T) {
+		m := MustBuild(TransactionID, BindingRequest)
+		if IsClassicMessage(m.Raw) {
+			t.Error("should not be classic message")
+		}
+		decodedModern := new(Message)
+		if err := DecodeClassic(m.Raw, decodedModern); err != nil {
+			t.Fatal(err)
+		}
+		if decodedModern.IsClassic() {
+			t.Error("should not be classic")
+		}
+		res := MustBuild(decodedModern, BindingSuccess)
+		if !IsMessage(res.Raw) {
+			t.Error("response should have magic cookie")
+		}
+	})
+}
+
+func TestIsClassicMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   []byte
+		out  bool
+	}{
+		{"Short", make([]byte, 10), false},
+		{"Header", make([]byte, 20), true},
+		{"BadFirstBits", append([]byte{0x80}, make([]byte, 19)...), false},
+		{"BadLength", append([]byte{0, 1, 0, 4}, make([]byte, 16)...), false},
+		{"NotPadded", append([]byte{0, 1, 0, 2}, make([]byte, 18)...), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsClassicMessage(tc.in); got != tc.out {
+				t.Errorf("%v (got) != %v (expected)", got, tc.out)
+			}
+		})
+	}
+}
+
+func TestXORMappedAddressOld(t *testing.T) {
+	m := MustBuild(TransactionID, BindingSuccess)
+	addr := XORMappedAddress{IP: net.IPv4(1, 2, 3, 4), Port: 3478}
+	if err := addr.AddToAs(m, AttrXORMappedAddressOld); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(XORMappedAddress).GetFrom(m); err != ErrAttributeNotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+	var got XORMappedAddress
+	if err := got.GetFromAs(m, AttrXORMappedAddressOld); err != nil {
+		t.Fatal(err)
+	}
+	if !got.IP.Equal(addr.IP) || got.Port != addr.Port {
+		t.Error("got bad address: ", got)
+	}
+	if AttrXORMappedAddressOld.String() != "XOR-MAPPED-ADDRESS-OLD" {
+		t.Error("bad string", AttrXORMappedAddressOld)
+	}
+}
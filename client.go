@@ -10,6 +10,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"gortc.io/stun/internal/clockutil"
 )
 
 // Dial connects to the address on the named network and then
@@ -136,10 +138,7 @@ func NewClient(conn Connection, options ...ClientOption) (*Client, error) {
 		return nil, err
 	}
 	if c.collector == nil {
-		c.collector = &tickerCollector{
-			close: make(chan struct{}),
-			clock: c.clock,
-		}
+		c.collector = clockutil.NewCollector(c.clock)
 	}
 	if err := c.collector.Start(c.rtoRate, func(t time.Time) {
 		closedOrPanic(c.a.Collect(t))
@@ -217,6 +216,27 @@ type clientTransaction struct {
 	start   time.Time
 	rto     time.Duration
 	raw     []byte
+
+	maxAttempts int32
+}
+
+// TransactionOption sets some option of single client transaction,
+// overriding client option.
+type TransactionOption func(t *clientTransaction)
+
+// WithTransactionRTO sets RTO of transaction.
+func WithTransactionRTO(rto time.Duration) TransactionOption {
+	return func(t *clientTransaction) {
+		t.rto = rto
+	}
+}
+
+// WithMaxAttempts sets maximum number of transaction retransmissions,
+// zero disables retransmissions.
+func WithMaxAttempts(n int) TransactionOption {
+	return func(t *clientTransaction) {
+		t.maxAttempts = int32(n)
+	}
 }
 
 func (t *clientTransaction) handle(e Event) {
@@ -271,11 +291,7 @@ type Clock interface {
 	Now() time.Time
 }
 
-type systemClockService struct{}
-
-func (systemClockService) Now() time.Time { return time.Now() }
-
-var systemClock = systemClockService{}
+var systemClock = clockutil.System{}
 
 // SetRTO sets current RTO value.
 func (c *Client) SetRTO(rto time.Duration) {
@@ -336,12 +352,6 @@ func closedOrPanic(err error) {
 	panic(err)
 }
 
-type tickerCollector struct {
-	close chan struct{}
-	wg    sync.WaitGroup
-	clock Clock
-}
-
 // Collector calls function f with constant rate.
 //
 // The simple Collector is ticker which calls function on each tick.
@@ -350,30 +360,6 @@ type Collector interface {
 	Close() error
 }
 
-func (a *tickerCollector) Start(rate time.Duration, f func(now time.Time)) error {
-	t := time.NewTicker(rate)
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		for {
-			select {
-			case <-a.close:
-				t.Stop()
-				return
-			case <-t.C:
-				f(a.clock.Now())
-			}
-		}
-	}()
-	return nil
-}
-
-func (a *tickerCollector) Close() error {
-	close(a.close)
-	a.wg.Wait()
-	return nil
-}
-
 // ErrClientClosed indicates that client is closed.
 var ErrClientClosed = errors.New("client is closed")
 
@@ -479,6 +465,11 @@ func (c *Client) checkInit() error {
 // Do has cpu overhead due to blocking, see BenchmarkClient_Do.
 // Use Start method for less overhead.
 func (c *Client) Do(m *Message, f func(Event)) error {
+	return c.DoWithOptions(m, f)
+}
+
+// DoWithOptions is Do with transaction options.
+func (c *Client) DoWithOptions(m *Message, f func(Event), options ...TransactionOption) error {
 	if err := c.checkInit(); err != nil {
 		return err
 	}
@@ -490,7 +481,7 @@ func (c *Client) Do(m *Message, f func(Event)) error {
 	defer func() {
 		callbackWaitHandlerPool.Put(h)
 	}()
-	if err := c.Start(m, h.handler); err != nil {
+	if err := c.StartWithOptions(m, h.handler, options...); err != nil {
 		return err
 	}
 	h.wait()
@@ -533,7 +524,7 @@ func (c *Client) handleAgentCallback(e Event) {
 		// Ignoring.
 		return
 	}
-	if atomic.LoadInt32(&c.maxAttempts) <= t.attempt || e.Error == nil {
+	if t.maxAttempts <= t.attempt || e.Error == nil {
 		// Transaction completed.
 		t.handle(e)
 		putClientTransaction(t)
@@ -589,6 +580,12 @@ func (c *Client) handleAgentCallback(e Event) {
 // Start starts transaction (if h set) and writes message to server, handler
 // is called asynchronously.
 func (c *Client) Start(m *Message, h Handler) error {
+	return c.StartWithOptions(m, h)
+}
+
+// StartWithOptions is Start with transaction options, which are applied
+// only if h is set.
+func (c *Client) StartWithOptions(m *Message, h Handler, options ...TransactionOption) error {
 	if err := c.checkInit(); err != nil {
 		return err
 	}
@@ -608,6 +605,10 @@ func (c *Client) Start(m *Message, h Handler) error {
 		t.attempt = 0
 		t.raw = append(t.raw[:0], m.Raw...)
 		t.calls = 0
+		t.maxAttempts = atomic.LoadInt32(&c.maxAttempts)
+		for _, o := range options {
+			o(t)
+		}
 		d := t.nextTimeout(t.start)
 		if err := c.start(t); err != nil {
 			return err
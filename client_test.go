@@ -1030,6 +1030,54 @@ func TestWithNoRetransmit(t *testing.T) {
 	<-gotReads
 }
 
+func TestClient_TransactionOptions(t *testing.T) {
+	response := MustBuild(TransactionID, BindingSuccess)
+	response.Encode()
+	connL, connR := net.Pipe()
+	defer connL.Close()
+	collector := new(manualCollector)
+	clock := &manualClock{current: time.Now()}
+	agent := &manualAgent{}
+	attempt := 0
+	agent.start = func(id [TransactionIDSize]byte, deadline time.Time) error {
+		if d := deadline.Sub(clock.Now()); d != time.Millisecond*500 {
+			t.Errorf("unexpected timeout %s", d)
+		}
+		if attempt > 0 {
+			t.Error("there should be no second attempt")
+		}
+		attempt++
+		go agent.h(Event{
+			TransactionID: id,
+			Error:         ErrTransactionTimeOut,
+		})
+		return nil
+	}
+	c, err := NewClient(connR,
+		WithAgent(agent),
+		WithClock(clock),
+		WithCollector(collector),
+		WithRTO(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		if _, readErr := connL.Read(buf); readErr != nil {
+			t.Error(readErr)
+		}
+	}()
+	if doErr := c.DoWithOptions(MustBuild(response, BindingRequest), func(event Event) {
+		if event.Error != ErrTransactionTimeOut {
+			t.Error("unexpected error")
+		}
+	}, WithTransactionRTO(time.Millisecond*500), WithMaxAttempts(0)); doErr != nil {
+		t.Fatal(doErr)
+	}
+}
+
 type callbackClock func() time.Time
 
 func (c callbackClock) Now() time.Time {
@@ -25,7 +25,16 @@ func main() {
 	m := new(stun.Message)
 	m.Raw = data
 	if err = m.Decode(); err != nil {
+		if dErr, ok := err.(*stun.DecodeErr); ok && dErr.Bytes(data) != nil {
+			fmt.Fprintln(os.Stderr, highlight(data, dErr.Offset, dErr.Size))
+		}
 		log.Fatalln("Unable to decode message:", err)
 	}
 	fmt.Println(m)
 }
+
+// highlight returns hex dump of data with size bytes at offset
+// enclosed in brackets.
+func highlight(data []byte, offset, size int) string {
+	return fmt.Sprintf("%x[%x]%x", data[:offset], data[offset:offset+size], data[offset+size:])
+}
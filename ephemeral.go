@@ -0,0 +1,107 @@
+package stun
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCredentialsExpired means that expiry timestamp of ephemeral username
+// is in the past.
+var ErrCredentialsExpired = errors.New("credentials expired")
+
+// ErrMalformedUsername means that ephemeral username has no valid expiry
+// timestamp.
+var ErrMalformedUsername = errors.New("malformed ephemeral username")
+
+// EphemeralOption sets some option of EphemeralCredentials.
+type EphemeralOption func(c *EphemeralCredentials)
+
+// WithEphemeralClock sets Clock of credentials, the source of current time
+// for generating and checking expiry timestamps.
+func WithEphemeralClock(clock Clock) EphemeralOption {
+	return func(c *EphemeralCredentials) {
+		c.clock = clock
+	}
+}
+
+// EphemeralCredentials generates and validates time-limited long-term
+// credentials of "TURN REST API", that are derived from secret shared by
+// web service and TURN server:
+//
+//	username = expiry timestamp [":" user]
+//	password = base64(HMAC-SHA1(secret, username))
+//
+// Expiry timestamp is seconds since unix epoch.
+//
+// draft-uberti-behave-turn-rest-00 Section 2.2
+type EphemeralCredentials struct {
+	secret []byte
+	ttl    time.Duration
+	clock  Clock
+}
+
+// ephemeralSep separates expiry timestamp and user in username.
+const ephemeralSep = ":"
+
+// NewEphemeralCredentials returns EphemeralCredentials with shared secret
+// that generates credentials valid for ttl.
+func NewEphemeralCredentials(secret string, ttl time.Duration, options ...EphemeralOption) *EphemeralCredentials {
+	c := &EphemeralCredentials{
+		secret: []byte(secret),
+		ttl:    ttl,
+		clock:  systemClock,
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+// Generate returns username and password of user, which can be empty,
+// that expire after ttl.
+func (c *EphemeralCredentials) Generate(user string) (username, password string) {
+	username = strconv.FormatInt(c.clock.Now().Add(c.ttl).Unix(), 10)
+	if user != "" {
+		username += ephemeralSep + user
+	}
+	return username, c.Password(username)
+}
+
+// Password returns password of username without checking expiry.
+func (c *EphemeralCredentials) Password(username string) string {
+	mac := hmac.New(sha1.New, c.secret)
+	writeOrPanic(mac, []byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Validate returns password of username, returning ErrMalformedUsername
+// or ErrCredentialsExpired if username is not valid.
+func (c *EphemeralCredentials) Validate(username string) (string, error) {
+	timestamp := username
+	if i := strings.Index(username, ephemeralSep); i >= 0 {
+		timestamp = username[:i]
+	}
+	expiry, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrMalformedUsername
+	}
+	if !c.clock.Now().Before(time.Unix(expiry, 0)) {
+		return "", ErrCredentialsExpired
+	}
+	return c.Password(username), nil
+}
+
+// Integrity returns MessageIntegrity with long-term credentials key of
+// username in realm, returning error if username is not valid.
+func (c *EphemeralCredentials) Integrity(username, realm string) (MessageIntegrity, error) {
+	password, err := c.Validate(username)
+	if err != nil {
+		return nil, err
+	}
+	return NewLongTermIntegrity(username, realm, password), nil
+}
@@ -0,0 +1,54 @@
+package stun
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEphemeralCredentials(t *testing.T) {
+	clock := &manualClock{current: time.Unix(1433350800, 0)}
+	c := NewEphemeralCredentials("north", time.Hour, WithEphemeralClock(clock))
+	username, password := c.Generate("alice")
+	if username != "1433354400:alice" {
+		t.Errorf("unexpected username %q", username)
+	}
+	if password != "7ZRODZ2YGR6jCCDA9SKOdoxqxEg=" {
+		t.Errorf("unexpected password %q", password)
+	}
+	if got, err := c.Validate(username); err != nil || got != password {
+		t.Errorf("unexpected password %q, error %v", got, err)
+	}
+	i, err := c.Integrity(username, "realm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(i, NewLongTermIntegrity(username, "realm", password)) {
+		t.Error("unexpected integrity")
+	}
+	t.Run("NoUser", func(t *testing.T) {
+		username, password := c.Generate("")
+		if username != "1433354400" {
+			t.Errorf("unexpected username %q", username)
+		}
+		if got, err := c.Validate(username); err != nil || got != password {
+			t.Errorf("unexpected password %q, error %v", got, err)
+		}
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		for _, username := range []string{"", "alice", "alice:1433354400"} {
+			if _, err := c.Validate(username); err != ErrMalformedUsername {
+				t.Errorf("%q: unexpected error %v", username, err)
+			}
+		}
+	})
+	t.Run("Expired", func(t *testing.T) {
+		clock.Add(time.Hour)
+		if _, err := c.Validate(username); err != ErrCredentialsExpired {
+			t.Errorf("unexpected error %v", err)
+		}
+		if _, err := c.Integrity(username, "realm"); err != ErrCredentialsExpired {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
@@ -6,6 +6,30 @@ import "errors"
 type DecodeErr struct {
 	Place   DecodeErrPlace
 	Message string
+	// Offset and Size are position and size of faulty bytes in
+	// Message.Raw. Position is known only if Size is positive, so zero
+	// value has no position; errors returned by Decode with unknown
+	// position also have Offset set to -1.
+	Offset int
+	Size   int
+	// AttrIndex is index of attribute that is being decoded or -1 if
+	// error is not related to attribute. AttrType is zero if attribute
+	// header is not decoded.
+	AttrIndex int
+	AttrType  AttrType
+	// Expected and Actual are lengths in bytes if error is caused by
+	// length mismatch, otherwise zero.
+	Expected int
+	Actual   int
+}
+
+// Bytes returns faulty bytes of raw, that are highlighted by Offset and
+// Size, or nil if position is unknown or out of raw.
+func (e DecodeErr) Bytes(raw []byte) []byte {
+	if e.Offset < 0 || e.Size <= 0 || e.Offset+e.Size > len(raw) {
+		return nil
+	}
+	return raw[e.Offset : e.Offset+e.Size]
 }
 
 // IsInvalidCookie returns true if error means that magic cookie
@@ -45,11 +69,31 @@ func (e DecodeErr) Error() string {
 
 func newDecodeErr(parent, children, message string) *DecodeErr {
 	return &DecodeErr{
-		Place:   DecodeErrPlace{Parent: parent, Children: children},
-		Message: message,
+		Place:     DecodeErrPlace{Parent: parent, Children: children},
+		Message:   message,
+		Offset:    -1,
+		AttrIndex: -1,
 	}
 }
 
+// at sets position and size of faulty bytes.
+func (e *DecodeErr) at(offset, size int) *DecodeErr {
+	e.Offset, e.Size = offset, size
+	return e
+}
+
+// attr sets index and type of attribute that is being decoded.
+func (e *DecodeErr) attr(i int, t AttrType) *DecodeErr {
+	e.AttrIndex, e.AttrType = i, t
+	return e
+}
+
+// lengths sets expected and actual lengths.
+func (e *DecodeErr) lengths(expected, actual int) *DecodeErr {
+	e.Expected, e.Actual = expected, actual
+	return e
+}
+
 // TODO(ar): rewrite errors to be more precise.
 func newAttrDecodeErr(children, message string) *DecodeErr {
 	return newDecodeErr("attribute", children, message)
@@ -30,3 +30,103 @@ func TestDecodeErr_IsInvalidCookie(t *testing.T) {
 		t.Error("bad parent")
 	}
 }
+
+func TestDecodeErr_Position(t *testing.T) {
+	m := MustBuild(TransactionID, BindingRequest, NewSoftware("soft"), NewUsername("username"))
+	for _, tc := range []struct {
+		name     string
+		setup    func(raw []byte) []byte
+		options  DecodeOption
+		expected DecodeErr
+	}{
+		{
+			name: "Cookie",
+			setup: func(raw []byte) []byte {
+				raw[4] = 55
+				return raw
+			},
+			expected: DecodeErr{
+				Place:  DecodeErrPlace{"message", "cookie"},
+				Offset: 4, Size: 4, AttrIndex: -1,
+			},
+		},
+		{
+			name: "Message",
+			setup: func(raw []byte) []byte {
+				return raw[:len(raw)-4]
+			},
+			expected: DecodeErr{
+				Place:  DecodeErrPlace{"attribute", "message"},
+				Offset: 2, Size: 2, AttrIndex: -1,
+				Expected: 40, Actual: 36,
+			},
+		},
+		{
+			name: "Header",
+			setup: func(raw []byte) []byte {
+				bin.PutUint16(raw[2:4], 10)
+				return raw[:30]
+			},
+			expected: DecodeErr{
+				Place:  DecodeErrPlace{"attribute", "header"},
+				Offset: 28, Size: 2, AttrIndex: 1,
+				Expected: 4, Actual: 2,
+			},
+		},
+		{
+			name: "Value",
+			setup: func(raw []byte) []byte {
+				bin.PutUint16(raw[2:4], 16)
+				return raw[:36]
+			},
+			expected: DecodeErr{
+				Place:  DecodeErrPlace{"attribute", "value"},
+				Offset: 28, Size: 8, AttrIndex: 1, AttrType: AttrUsername,
+				Expected: 8, Actual: 4,
+			},
+		},
+		{
+			name: "Padding",
+			setup: func(raw []byte) []byte {
+				m := MustBuild(TransactionID, BindingRequest, NewSoftware("soft"), NewUsername("user1"))
+				m.Raw[len(m.Raw)-1] = 1
+				return m.Raw
+			},
+			options: StrictDecoding,
+			expected: DecodeErr{
+				Place:  DecodeErrPlace{"attribute", "padding"},
+				Offset: 37, Size: 3, AttrIndex: 1, AttrType: AttrUsername,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := tc.setup(append([]byte{}, m.Raw...))
+			err := DecodeWith(raw, new(Message), tc.options)
+			dErr, ok := err.(*DecodeErr)
+			if !ok {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tc.expected.Message = dErr.Message
+			if *dErr != tc.expected {
+				t.Errorf("%#v (got) != %#v (expected)", *dErr, tc.expected)
+			}
+			if b := dErr.Bytes(raw); len(b) != tc.expected.Size {
+				t.Errorf("unexpected bytes %x", b)
+			}
+		})
+	}
+	t.Run("Zero", func(t *testing.T) {
+		if b := (DecodeErr{}).Bytes(m.Raw); b != nil {
+			t.Errorf("unexpected bytes %x", b)
+		}
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		dErr := newDecodeErr("parent", "children", "message")
+		if b := dErr.Bytes(m.Raw); b != nil {
+			t.Errorf("unexpected bytes %x", b)
+		}
+		if b := dErr.at(len(m.Raw)-1, 2).Bytes(m.Raw); b != nil {
+			t.Errorf("unexpected bytes %x", b)
+		}
+	})
+}
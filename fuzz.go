@@ -52,6 +52,24 @@ func FuzzType(data []byte) int {
 	return 0
 }
 
+// FuzzChannelData is go-fuzz endpoint for ChannelData.
+func FuzzChannelData(data []byte) int {
+	d := &ChannelData{Raw: data}
+	if err := d.Decode(); err != nil {
+		return 0
+	}
+	d2 := &ChannelData{Number: d.Number, Data: d.Data}
+	d2.Encode()
+	d3 := &ChannelData{Raw: d2.Raw}
+	if err := d3.Decode(); err != nil {
+		panic(err)
+	}
+	if !d3.Equal(d) {
+		panic("not equal")
+	}
+	return 1
+}
+
 type attr interface {
 	Getter
 	Setter
@@ -93,6 +111,35 @@ func FuzzSetters(data []byte) int {
 		{new(Username), AttrUsername},
 		{new(MappedAddress), AttrMappedAddress},
 		{new(Realm), AttrRealm},
+		{new(ChangeRequest), AttrChangeRequest},
+		{new(ResponsePort), AttrResponsePort},
+		{new(ResponseOrigin), AttrResponseOrigin},
+		{new(OtherAddress), AttrOtherAddress},
+		{new(ResponseAddress), AttrResponseAddress},
+		{new(SourceAddress), AttrSourceAddress},
+		{new(ChangedAddress), AttrChangedAddress},
+		{new(ReflectedFrom), AttrReflectedFrom},
+		{new(Priority), AttrPriority},
+		{new(ICEControlled), AttrICEControlled},
+		{new(ICEControlling), AttrICEControlling},
+		{new(ChannelNumber), AttrChannelNumber},
+		{new(Lifetime), AttrLifetime},
+		{new(XORPeerAddress), AttrXORPeerAddress},
+		{new(Data), AttrData},
+		{new(XORRelayedAddress), AttrXORRelayedAddress},
+		{new(EvenPort), AttrEvenPort},
+		{new(RequestedTransport), AttrRequestedTransport},
+		{new(ReservationToken), AttrReservationToken},
+		{new(RequestedAddressFamily), AttrRequestedAddressFamily},
+		{new(ConnectionID), AttrConnectionID},
+		{new(XORRelayedAddresses), AttrXORRelayedAddress},
+		{new(AdditionalAddressFamily), AttrAdditionalAddressFamily},
+		{new(AddressErrorCode), AttrAddressErrorCode},
+		{new(ICMP), AttrICMP},
+		{new(Origin), AttrOrigin},
+		{new(Origins), AttrOrigin},
+		{new(ThirdPartyAuthorization), AttrThirdPartyAuthorization},
+		{new(AccessToken), AttrAccessToken},
 	}
 	firstByte := byte(0)
 	if len(data) > 0 {
@@ -0,0 +1,45 @@
+package stun
+
+import (
+	"net"
+	"time"
+)
+
+const defaultHairpinTimeout = time.Second
+
+// DetectHairpinning reports whether NAT supports hairpinning. The mapped
+// address of x is obtained from server via Binding request, then Binding
+// request is sent from y to that address; hairpinning is supported if the
+// request is received on x during timeout.
+//
+// Both x and y must be behind the same NAT and must not be read by
+// anything else until DetectHairpinning returns. If timeout is zero,
+// one second is used. Options are passed to the underlying Client.
+//
+// RFC 5780 Section 4.5
+func DetectHairpinning(x, y net.PacketConn, server net.Addr, timeout time.Duration, options ...ClientOption) (bool, error) {
+	if timeout == 0 {
+		timeout = defaultHairpinTimeout
+	}
+	p, err := newNATProber(x, y, server, timeout, options...)
+	if err != nil {
+		return false, err
+	}
+	supported, err := p.detectHairpinning()
+	if err = p.close(err); err != nil {
+		return false, err
+	}
+	return supported, nil
+}
+
+func (p *natProber) detectHairpinning() (bool, error) {
+	mapped, err := p.bind()
+	if err != nil {
+		return false, err
+	}
+	m, err := Build(TransactionID, BindingRequest)
+	if err != nil {
+		return false, err
+	}
+	return p.sendAndWait(m, &net.UDPAddr{IP: mapped.IP, Port: mapped.Port})
+}
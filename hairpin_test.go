@@ -0,0 +1,67 @@
+package stun
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDetectHairpinning(t *testing.T) {
+	listen := func(t *testing.T) net.PacketConn {
+		t.Helper()
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	t.Run("Supported", func(t *testing.T) {
+		s := new(natTestServer)
+		s.start(t)
+		defer s.Close()
+		x, y := listen(t), listen(t)
+		defer x.Close()
+		defer y.Close()
+		supported, err := DetectHairpinning(x, y, s.addr(0, 0), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !supported {
+			t.Error("should be supported")
+		}
+	})
+	t.Run("NotSupported", func(t *testing.T) {
+		// Simulating NAT that does not forward hairpinned packets by
+		// mapping to the port that is not used.
+		unused := listen(t)
+		unusedAddr := unused.LocalAddr().(*net.UDPAddr)
+		unused.Close()
+		s := &natTestServer{
+			mapped: func(addr *net.UDPAddr, ip, port int) *net.UDPAddr {
+				return unusedAddr
+			},
+		}
+		s.start(t)
+		defer s.Close()
+		x, y := listen(t), listen(t)
+		defer x.Close()
+		defer y.Close()
+		supported, err := DetectHairpinning(x, y, s.addr(0, 0), time.Millisecond*100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if supported {
+			t.Error("should not be supported")
+		}
+	})
+	t.Run("NoResponse", func(t *testing.T) {
+		server := listen(t)
+		defer server.Close()
+		x, y := listen(t), listen(t)
+		defer x.Close()
+		defer y.Close()
+		if _, err := DetectHairpinning(x, y, server.LocalAddr(), 0, WithRTO(time.Millisecond)); err != ErrTransactionTimeOut {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
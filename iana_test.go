@@ -69,6 +69,15 @@ func TestIANA(t *testing.T) {
 		// Not registered in IANA.
 		for k, v := range map[string]AttrType{
 			"ORIGIN": 0x802F,
+			// Reserved in RFC 5389, but re-used in RFC 5780.
+			"CHANGE-REQUEST": 0x0003,
+			// Reserved in RFC 5389, used by RFC 3489 classic STUN.
+			"RESPONSE-ADDRESS": 0x0002,
+			"SOURCE-ADDRESS":   0x0004,
+			"CHANGED-ADDRESS":  0x0005,
+			"REFLECTED-FROM":   0x000B,
+			// Used by pre-RFC 5389 drafts.
+			"XOR-MAPPED-ADDRESS-OLD": 0x8020,
 		} {
 			m[k] = v
 		}
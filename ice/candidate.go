@@ -0,0 +1,350 @@
+package ice
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+
+	"gortc.io/stun"
+)
+
+// CandidateType is type of ICE candidate.
+//
+// RFC 8445 Section 5.1.1
+type CandidateType byte
+
+// Possible candidate types.
+const (
+	CandidateUnknown CandidateType = iota
+	Host
+	ServerReflexive
+	PeerReflexive
+	Relayed
+)
+
+var candidateTypeNames = map[CandidateType]string{
+	Host:            "host",
+	ServerReflexive: "srflx",
+	PeerReflexive:   "prflx",
+	Relayed:         "relay",
+}
+
+// String returns SDP representation of candidate type.
+func (t CandidateType) String() string {
+	if s, ok := candidateTypeNames[t]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// Preference returns recommended type preference.
+//
+// RFC 8445 Section 5.1.2.2
+func (t CandidateType) Preference() int {
+	switch t {
+	case Host:
+		return 126
+	case PeerReflexive:
+		return 110
+	case ServerReflexive:
+		return 100
+	default:
+		return 0
+	}
+}
+
+// TCPType is type of TCP candidate.
+//
+// RFC 6544 Section 4.5
+type TCPType byte
+
+// Possible TCP candidate types.
+const (
+	TCPTypeUnknown TCPType = iota
+	TCPActive
+	TCPPassive
+	TCPSimultaneousOpen
+)
+
+var tcpTypeNames = map[TCPType]string{
+	TCPActive:           "active",
+	TCPPassive:          "passive",
+	TCPSimultaneousOpen: "so",
+}
+
+func (t TCPType) String() string {
+	if s, ok := tcpTypeNames[t]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// CandidateExtension is name-value pair of candidate attribute extension,
+// like "generation 0".
+type CandidateExtension struct {
+	Name  string
+	Value string
+}
+
+// Candidate is ICE candidate.
+//
+// RFC 8445 Section 5.1
+type Candidate struct {
+	Foundation  string
+	Component   int
+	Transport   string // "udp" or "tcp"
+	Priority    stun.Priority
+	IP          net.IP
+	Hostname    string // FQDN connection-address, like mDNS name, if IP is nil
+	Port        int
+	Type        CandidateType
+	RelatedIP   net.IP // base of srflx and prflx, mapped address of relay
+	RelatedPort int
+	TCPType     TCPType // only for "tcp" transport
+	Extensions  []CandidateExtension
+}
+
+// Priority computes candidate priority.
+//
+// RFC 8445 Section 5.1.2.1
+func Priority(typePreference, localPreference, component int) stun.Priority {
+	return stun.Priority(typePreference<<24 | localPreference<<8 | (256 - component))
+}
+
+// DefaultLocalPreference is local preference for candidates of
+// single-homed agent.
+const DefaultLocalPreference = 65535
+
+// TCPLocalPreference computes local preference of TCP candidate with
+// otherPreference in range from 0 to 8191.
+//
+// RFC 6544 Section 4.2
+func TCPLocalPreference(t CandidateType, tcpType TCPType, otherPreference int) int {
+	var direction int
+	switch tcpType {
+	case TCPActive:
+		direction = 6
+		if t != Host {
+			direction = 4
+		}
+	case TCPPassive:
+		direction = 4
+		if t != Host {
+			direction = 2
+		}
+	case TCPSimultaneousOpen:
+		direction = 2
+		if t != Host {
+			direction = 6
+		}
+	}
+	return direction<<13 | otherPreference
+}
+
+// PeerReflexivePriority returns value of PRIORITY attribute for
+// connectivity checks from c: the priority computed as for c but with
+// peer-reflexive type preference.
+//
+// RFC 8445 Section 7.1.1
+func (c Candidate) PeerReflexivePriority() stun.Priority {
+	localPreference := int(c.Priority>>8) & 0xFFFF
+	return Priority(PeerReflexive.Preference(), localPreference, c.Component)
+}
+
+// Foundation computes foundation for candidate of type t with base IP,
+// transport and IP of the STUN or TURN server, which can be nil.
+// Candidates with same type, base IP, server IP and transport have
+// equal foundations.
+//
+// RFC 8445 Section 5.1.1.3
+func Foundation(t CandidateType, base, server net.IP, transport string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(t.String()))
+	_, _ = h.Write(base.To16())
+	_, _ = h.Write(server.To16())
+	_, _ = h.Write([]byte(strings.ToLower(transport)))
+	return strconv.FormatUint(uint64(h.Sum32()), 10)
+}
+
+// NewServerReflexive returns server-reflexive candidate from Binding
+// success response that was received on base from server.
+func NewServerReflexive(res *stun.Message, base, server *net.UDPAddr, component, localPreference int) (Candidate, error) {
+	var mapped stun.XORMappedAddress
+	if err := mapped.GetFrom(res); err != nil {
+		return Candidate{}, err
+	}
+	return Candidate{
+		Foundation:  Foundation(ServerReflexive, base.IP, server.IP, "udp"),
+		Component:   component,
+		Transport:   "udp",
+		Priority:    Priority(ServerReflexive.Preference(), localPreference, component),
+		IP:          mapped.IP,
+		Port:        mapped.Port,
+		Type:        ServerReflexive,
+		RelatedIP:   base.IP,
+		RelatedPort: base.Port,
+	}, nil
+}
+
+const candidatePrefix = "candidate:"
+
+// address returns connection-address of candidate.
+func (c Candidate) address() string {
+	if c.IP == nil && c.Hostname != "" {
+		return c.Hostname
+	}
+	return c.IP.String()
+}
+
+// String returns SDP "candidate" attribute value, like
+// "candidate:1 1 udp 2130706431 10.0.1.1 8998 typ host".
+//
+// RFC 8839 Section 5.1
+func (c Candidate) String() string {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "%s%s %d %s %d %s %d typ %s", candidatePrefix,
+		c.Foundation, c.Component, c.Transport, c.Priority, c.address(), c.Port, c.Type,
+	)
+	if c.RelatedIP != nil {
+		fmt.Fprintf(b, " raddr %s rport %d", c.RelatedIP, c.RelatedPort)
+	}
+	if c.TCPType != TCPTypeUnknown {
+		fmt.Fprintf(b, " tcptype %s", c.TCPType)
+	}
+	for _, e := range c.Extensions {
+		fmt.Fprintf(b, " %s %s", e.Name, e.Value)
+	}
+	return b.String()
+}
+
+// ErrCandidateTooShort means that candidate attribute has not enough
+// fields.
+var ErrCandidateTooShort = errors.New("not enough fields in candidate")
+
+// ParseCandidate parses SDP "candidate" attribute, "a=" prefix is optional.
+// Connection-address that is FQDN, like mDNS "<uuid>.local" name, is
+// stored in Hostname and is not resolved.
+//
+// RFC 8839 Section 5.1
+func ParseCandidate(s string) (Candidate, error) {
+	s = strings.TrimPrefix(s, "a=")
+	if !strings.HasPrefix(s, candidatePrefix) {
+		return Candidate{}, fmt.Errorf("no %q prefix", candidatePrefix)
+	}
+	f := strings.Fields(s[len(candidatePrefix):])
+	if len(f) < 8 {
+		return Candidate{}, ErrCandidateTooShort
+	}
+	var (
+		c   = Candidate{Foundation: f[0], Transport: f[2]}
+		err error
+	)
+	if c.Component, err = strconv.Atoi(f[1]); err != nil || c.Component < 1 || c.Component > 256 {
+		return Candidate{}, fmt.Errorf("bad component %q", f[1])
+	}
+	priority, err := strconv.ParseUint(f[3], 10, 32)
+	if err != nil || priority == 0 {
+		return Candidate{}, fmt.Errorf("bad priority %q", f[3])
+	}
+	c.Priority = stun.Priority(priority)
+	if isHostname(f[4]) {
+		c.Hostname = f[4]
+		c.Port, err = parsePort(f[5])
+	} else {
+		c.IP, c.Port, err = parseAddr(f[4], f[5])
+	}
+	if err != nil {
+		return Candidate{}, err
+	}
+	if f[6] != "typ" {
+		return Candidate{}, fmt.Errorf("expected \"typ\", got %q", f[6])
+	}
+	for t, name := range candidateTypeNames {
+		if name == f[7] {
+			c.Type = t
+		}
+	}
+	if c.Type == CandidateUnknown {
+		return Candidate{}, fmt.Errorf("unknown candidate type %q", f[7])
+	}
+	f = f[8:]
+	if len(f)%2 != 0 {
+		return Candidate{}, fmt.Errorf("extension %q has no value", f[len(f)-1])
+	}
+	var relatedAddr, relatedPort string
+	for i := 0; i < len(f); i += 2 {
+		switch name, value := f[i], f[i+1]; name {
+		case "raddr":
+			relatedAddr = value
+		case "rport":
+			relatedPort = value
+		case "tcptype":
+			for t, n := range tcpTypeNames {
+				if n == value {
+					c.TCPType = t
+				}
+			}
+			if c.TCPType == TCPTypeUnknown {
+				return Candidate{}, fmt.Errorf("unknown tcp type %q", value)
+			}
+		default:
+			c.Extensions = append(c.Extensions, CandidateExtension{Name: name, Value: value})
+		}
+	}
+	if relatedAddr != "" || relatedPort != "" {
+		if c.RelatedIP, c.RelatedPort, err = parseAddr(relatedAddr, relatedPort); err != nil {
+			return Candidate{}, err
+		}
+	}
+	return c, nil
+}
+
+func parseAddr(ip, port string) (net.IP, int, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, 0, fmt.Errorf("bad address %q", ip)
+	}
+	p, err := parsePort(port)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parsed, p, nil
+}
+
+func parsePort(port string) (int, error) {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return 0, fmt.Errorf("bad port %q", port)
+	}
+	return p, nil
+}
+
+// isHostname reports whether s is syntactically valid FQDN: dot-separated
+// labels of letters, digits and hyphens, and top-level label is not
+// numeric, so malformed IPv4 address is not a hostname.
+//
+// RFC 1123 Section 2.1
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(l); i++ {
+			switch c := l[i]; {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
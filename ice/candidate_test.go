@@ -0,0 +1,183 @@
+package ice
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"gortc.io/stun"
+)
+
+func TestPriority(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		typ  CandidateType
+		pref int
+		comp int
+		out  stun.Priority
+	}{
+		{"HostRTP", Host, DefaultLocalPreference, 1, 2130706431},
+		{"HostRTCP", Host, DefaultLocalPreference, 2, 2130706430},
+		{"ServerReflexive", ServerReflexive, DefaultLocalPreference, 1, 1694498815},
+		{"PeerReflexive", PeerReflexive, DefaultLocalPreference, 1, 1862270975},
+		{"Relayed", Relayed, DefaultLocalPreference, 1, 16777215},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if p := Priority(tc.typ.Preference(), tc.pref, tc.comp); p != tc.out {
+				t.Errorf("%d (got) != %d (expected)", p, tc.out)
+			}
+		})
+	}
+	c := Candidate{Type: Host, Component: 1, Priority: Priority(Host.Preference(), 1000, 1)}
+	if p := c.PeerReflexivePriority(); p != Priority(PeerReflexive.Preference(), 1000, 1) {
+		t.Errorf("bad peer-reflexive priority %d", p)
+	}
+	if pref := TCPLocalPreference(Host, TCPActive, 8191); pref != 6<<13|8191 {
+		t.Errorf("bad tcp local preference %d", pref)
+	}
+}
+
+func TestFoundation(t *testing.T) {
+	var (
+		base   = net.IPv4(10, 0, 1, 1)
+		server = net.IPv4(192, 0, 2, 1)
+		a      = Foundation(ServerReflexive, base, server, "udp")
+	)
+	if a != Foundation(ServerReflexive, base, server, "UDP") {
+		t.Error("foundation should be equal for same parameters")
+	}
+	for _, f := range []string{
+		Foundation(Host, base, nil, "udp"),
+		Foundation(ServerReflexive, net.IPv4(10, 0, 1, 2), server, "udp"),
+		Foundation(ServerReflexive, base, net.IPv4(192, 0, 2, 2), "udp"),
+		Foundation(ServerReflexive, base, server, "tcp"),
+	} {
+		if f == a {
+			t.Error("foundation should differ")
+		}
+	}
+}
+
+func TestNewServerReflexive(t *testing.T) {
+	var (
+		base   = &net.UDPAddr{IP: net.IPv4(10, 0, 1, 1), Port: 8998}
+		server = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 3478}
+	)
+	res := stun.MustBuild(stun.TransactionID, stun.BindingSuccess,
+		&stun.XORMappedAddress{IP: net.IPv4(203, 0, 113, 5), Port: 45664},
+	)
+	c, err := NewServerReflexive(res, base, server, 1, DefaultLocalPreference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "candidate:" + Foundation(ServerReflexive, base.IP, server.IP, "udp") +
+		" 1 udp 1694498815 203.0.113.5 45664 typ srflx raddr 10.0.1.1 rport 8998"
+	if c.String() != expected {
+		t.Errorf("%q (got) != %q (expected)", c, expected)
+	}
+	if _, err = NewServerReflexive(new(stun.Message), base, server, 1, DefaultLocalPreference); err != stun.ErrAttributeNotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParseCandidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		out  Candidate
+	}{
+		{
+			name: "Host",
+			in:   "candidate:1 1 udp 2130706431 10.0.1.1 8998 typ host",
+			out: Candidate{
+				Foundation: "1", Component: 1, Transport: "udp", Priority: 2130706431,
+				IP: net.ParseIP("10.0.1.1"), Port: 8998, Type: Host,
+			},
+		},
+		{
+			name: "ServerReflexive",
+			in:   "candidate:2 1 UDP 1694498815 192.0.2.3 45664 typ srflx raddr 10.0.1.1 rport 8998",
+			out: Candidate{
+				Foundation: "2", Component: 1, Transport: "UDP", Priority: 1694498815,
+				IP: net.ParseIP("192.0.2.3"), Port: 45664, Type: ServerReflexive,
+				RelatedIP: net.ParseIP("10.0.1.1"), RelatedPort: 8998,
+			},
+		},
+		{
+			name: "TCP",
+			in:   "candidate:3 1 tcp 1518280447 2001:db8::1 9 typ host tcptype active generation 0",
+			out: Candidate{
+				Foundation: "3", Component: 1, Transport: "tcp", Priority: 1518280447,
+				IP: net.ParseIP("2001:db8::1"), Port: 9, Type: Host, TCPType: TCPActive,
+				Extensions: []CandidateExtension{{Name: "generation", Value: "0"}},
+			},
+		},
+		{
+			name: "Relayed",
+			in:   "candidate:4 2 udp 16777214 198.51.100.1 50000 typ relay raddr 192.0.2.3 rport 45664",
+			out: Candidate{
+				Foundation: "4", Component: 2, Transport: "udp", Priority: 16777214,
+				IP: net.ParseIP("198.51.100.1"), Port: 50000, Type: Relayed,
+				RelatedIP: net.ParseIP("192.0.2.3"), RelatedPort: 45664,
+			},
+		},
+		{
+			name: "MDNS",
+			in:   "candidate:5 1 udp 2122262783 1f4712db-ea17-4bcf-a596-105139dfd8bf.local 54321 typ host generation 0",
+			out: Candidate{
+				Foundation: "5", Component: 1, Transport: "udp", Priority: 2122262783,
+				Hostname: "1f4712db-ea17-4bcf-a596-105139dfd8bf.local", Port: 54321, Type: Host,
+				Extensions: []CandidateExtension{{Name: "generation", Value: "0"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseCandidate("a=" + tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, tc.out) {
+				t.Errorf("%+v (got) != %+v (expected)", c, tc.out)
+			}
+			if c.String() != tc.in {
+				t.Errorf("%q (got) != %q (expected)", c, tc.in)
+			}
+		})
+	}
+}
+
+func TestParseCandidateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+	}{
+		{"NoPrefix", "1 1 udp 2130706431 10.0.1.1 8998 typ host"},
+		{"Short", "candidate:1 1 udp 2130706431 10.0.1.1 8998 typ"},
+		{"BadComponent", "candidate:1 0 udp 2130706431 10.0.1.1 8998 typ host"},
+		{"BadPriority", "candidate:1 1 udp foo 10.0.1.1 8998 typ host"},
+		{"BadIP", "candidate:1 1 udp 2130706431 10.0.1 8998 typ host"},
+		{"BadHostname", "candidate:1 1 udp 2130706431 -example.local 8998 typ host"},
+		{"BadHostnamePort", "candidate:1 1 udp 2130706431 example.local 70000 typ host"},
+		{"BadPort", "candidate:1 1 udp 2130706431 10.0.1.1 70000 typ host"},
+		{"NoTyp", "candidate:1 1 udp 2130706431 10.0.1.1 8998 type host"},
+		{"BadType", "candidate:1 1 udp 2130706431 10.0.1.1 8998 typ foo"},
+		{"NoValue", "candidate:1 1 udp 2130706431 10.0.1.1 8998 typ host generation"},
+		{"BadTCPType", "candidate:1 1 tcp 2130706431 10.0.1.1 8998 typ host tcptype foo"},
+		{"BadRelated", "candidate:1 1 udp 2130706431 10.0.1.1 8998 typ srflx raddr foo rport 1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseCandidate(tc.in); err == nil {
+				t.Error("should error")
+			}
+		})
+	}
+}
+
+func TestCandidateType_String(t *testing.T) {
+	if CandidateUnknown.String() != "unknown" || TCPTypeUnknown.String() != "unknown" {
+		t.Error("bad unknown string")
+	}
+	if TCPSimultaneousOpen.String() != "so" || PeerReflexive.String() != "prflx" {
+		t.Error("bad string")
+	}
+}
@@ -0,0 +1,282 @@
+// Package ice implements parts of Interactive Connectivity Establishment
+// (ICE) protocol [RFC 8445] that are built on STUN.
+package ice
+
+import (
+	"errors"
+	"strings"
+
+	"gortc.io/stun"
+)
+
+// Role is ICE agent role.
+//
+// RFC 8445 Section 6.1.1
+type Role byte
+
+// Possible ICE agent roles.
+const (
+	RoleUnknown Role = iota
+	Controlling
+	Controlled
+)
+
+func (r Role) String() string {
+	switch r {
+	case Controlling:
+		return "controlling"
+	case Controlled:
+		return "controlled"
+	default:
+		return "unknown"
+	}
+}
+
+// Credentials are ICE username fragment and password of agent.
+//
+// RFC 8445 Section 5.3
+type Credentials struct {
+	Ufrag string
+	Pwd   string
+}
+
+// Check describes connectivity check request that is sent from local
+// agent to remote one.
+//
+// RFC 8445 Section 7.2.2
+type Check struct {
+	Local      Credentials
+	Remote     Credentials
+	Role       Role
+	TieBreaker uint64
+	// Priority is priority of peer-reflexive candidate that would be
+	// discovered by this check.
+	Priority stun.Priority
+	// UseCandidate is set by controlling agent to nominate pair.
+	UseCandidate bool
+}
+
+// ErrUnknownRole means that role is not Controlling or Controlled.
+var ErrUnknownRole = errors.New("unknown role")
+
+func (r Role) attr(tieBreaker uint64) (stun.Setter, error) {
+	switch r {
+	case Controlling:
+		return stun.ICEControlling(tieBreaker), nil
+	case Controlled:
+		return stun.ICEControlled(tieBreaker), nil
+	default:
+		return nil, ErrUnknownRole
+	}
+}
+
+// Build builds connectivity check request with new transaction id into m.
+// The USERNAME is "RFRAG:LFRAG" and MESSAGE-INTEGRITY is computed using
+// remote password, FINGERPRINT is added last.
+func (c Check) Build(m *stun.Message) error {
+	role, err := c.Role.attr(c.TieBreaker)
+	if err != nil {
+		return err
+	}
+	setters := []stun.Setter{
+		stun.TransactionID, stun.BindingRequest,
+		stun.NewUsername(c.Remote.Ufrag + ":" + c.Local.Ufrag),
+		c.Priority, role,
+	}
+	if c.UseCandidate {
+		setters = append(setters, stun.UseCandidate)
+	}
+	setters = append(setters,
+		stun.NewShortTermIntegrity(c.Remote.Pwd),
+		stun.Fingerprint,
+	)
+	return m.Build(setters...)
+}
+
+// Request is connectivity check request received by local agent.
+type Request struct {
+	// RemoteUfrag is username fragment of remote agent that sent request.
+	RemoteUfrag  string
+	Role         Role // role of remote agent
+	TieBreaker   uint64
+	Priority     stun.Priority
+	UseCandidate bool
+}
+
+// Errors that can be returned by ParseRequest, see ErrorCode for
+// corresponding response codes.
+var (
+	ErrNotBindingRequest = errors.New("not a binding request")
+	ErrNoFingerprint     = errors.New("no FINGERPRINT")
+	ErrNoUsername        = errors.New("no USERNAME")
+	ErrNoIntegrity       = errors.New("no MESSAGE-INTEGRITY")
+	ErrBadUsername       = errors.New("USERNAME does not match local ufrag")
+	ErrNoPriority        = errors.New("no PRIORITY")
+	ErrNoRole            = errors.New("no ICE-CONTROLLED or ICE-CONTROLLING")
+	ErrBothRoles         = errors.New("both ICE-CONTROLLED and ICE-CONTROLLING")
+)
+
+// ErrRoleConflict means that role conflict is detected and it should be
+// resolved by other side, so 487 error response should be sent on
+// request or role should be switched on response.
+var ErrRoleConflict = errors.New("role conflict")
+
+// ParseRequest validates connectivity check request m using credentials
+// of local agent, which is recipient of m.
+//
+// RFC 8445 Section 7.3
+func ParseRequest(m *stun.Message, local Credentials) (*Request, error) {
+	if m.Type != stun.BindingRequest {
+		return nil, ErrNotBindingRequest
+	}
+	if !m.Contains(stun.AttrFingerprint) {
+		return nil, ErrNoFingerprint
+	}
+	if err := stun.Fingerprint.Check(m); err != nil {
+		return nil, err
+	}
+	var username stun.Username
+	if err := username.GetFrom(m); err != nil {
+		return nil, ErrNoUsername
+	}
+	if !m.Contains(stun.AttrMessageIntegrity) {
+		return nil, ErrNoIntegrity
+	}
+	colon := strings.IndexByte(string(username), ':')
+	if colon < 0 || string(username[:colon]) != local.Ufrag {
+		return nil, ErrBadUsername
+	}
+	if err := stun.NewShortTermIntegrity(local.Pwd).Check(m); err != nil {
+		return nil, err
+	}
+	req := &Request{
+		RemoteUfrag:  string(username[colon+1:]),
+		UseCandidate: stun.UseCandidate.IsSet(m),
+	}
+	if err := req.Priority.GetFrom(m); err != nil {
+		return nil, ErrNoPriority
+	}
+	var (
+		controlled  stun.ICEControlled
+		controlling stun.ICEControlling
+	)
+	controlledErr := controlled.GetFrom(m)
+	controllingErr := controlling.GetFrom(m)
+	switch {
+	case controlledErr == nil && controllingErr == nil:
+		return nil, ErrBothRoles
+	case controlledErr == nil:
+		req.Role = Controlled
+		req.TieBreaker = uint64(controlled)
+	case controllingErr == nil:
+		req.Role = Controlling
+		req.TieBreaker = uint64(controlling)
+	default:
+		return nil, ErrNoRole
+	}
+	return req, nil
+}
+
+// ResolveRoleConflict resolves role conflict on request receipt, returning
+// new role of local agent. Returns ErrRoleConflict if 487 error response
+// should be sent, so remote agent will switch role.
+//
+// RFC 8445 Section 7.3.1.1
+func ResolveRoleConflict(role Role, tieBreaker uint64, req *Request) (Role, error) {
+	if role != req.Role {
+		return role, nil
+	}
+	switch role {
+	case Controlling:
+		if tieBreaker >= req.TieBreaker {
+			return role, ErrRoleConflict
+		}
+		return Controlled, nil
+	case Controlled:
+		if tieBreaker >= req.TieBreaker {
+			return Controlling, nil
+		}
+		return role, ErrRoleConflict
+	default:
+		return role, ErrUnknownRole
+	}
+}
+
+// ErrorCode returns error code of response that should be sent to request
+// that failed with err, or zero if request should be discarded silently.
+//
+// RFC 8445 Section 7.3
+func ErrorCode(err error) stun.ErrorCode {
+	if stun.IsIntegrityMismatch(err) {
+		return stun.CodeUnauthorized
+	}
+	switch err {
+	case ErrRoleConflict:
+		return stun.CodeRoleConflict
+	case ErrNoUsername, ErrNoIntegrity, ErrNoPriority, ErrNoRole, ErrBothRoles:
+		return stun.CodeBadRequest
+	case ErrBadUsername:
+		return stun.CodeUnauthorized
+	default:
+		return 0
+	}
+}
+
+// BuildSuccess builds success response to connectivity check request req
+// into res with mapped address, using credentials of local agent.
+//
+// RFC 8445 Section 7.3.1.3
+func BuildSuccess(res, req *stun.Message, mapped stun.XORMappedAddress, local Credentials) error {
+	return res.Build(req, stun.BindingSuccess, &mapped,
+		stun.NewShortTermIntegrity(local.Pwd), stun.Fingerprint,
+	)
+}
+
+// BuildError builds error response with code to connectivity check request
+// req into res. The 400 and 401 responses are not authenticated, others are
+// authenticated using credentials of local agent.
+func BuildError(res, req *stun.Message, code stun.ErrorCode, local Credentials) error {
+	setters := []stun.Setter{req, stun.BindingError, code}
+	if code != stun.CodeBadRequest && code != stun.CodeUnauthorized {
+		setters = append(setters, stun.NewShortTermIntegrity(local.Pwd))
+	}
+	return res.Build(append(setters, stun.Fingerprint)...)
+}
+
+// ErrUnexpectedResponse means that response has unexpected message type.
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// ParseResponse validates response res to connectivity check using
+// credentials of remote agent, returning mapped address from success
+// response. Returns ErrRoleConflict on 487 error response, so local agent
+// should switch role and retry check, and stun.ResponseErr on other
+// authenticated error responses.
+//
+// RFC 8445 Section 7.2.5
+func ParseResponse(res *stun.Message, remote Credentials) (stun.XORMappedAddress, error) {
+	var mapped stun.XORMappedAddress
+	if res.Type.Method != stun.MethodBinding {
+		return mapped, ErrUnexpectedResponse
+	}
+	if err := stun.Fingerprint.Check(res); err != nil {
+		return mapped, err
+	}
+	if err := stun.NewShortTermIntegrity(remote.Pwd).Check(res); err != nil {
+		return mapped, err
+	}
+	switch res.Type.Class {
+	case stun.ClassSuccessResponse:
+		return mapped, mapped.GetFrom(res)
+	case stun.ClassErrorResponse:
+		var code stun.ErrorCodeAttribute
+		if err := code.GetFrom(res); err != nil {
+			return mapped, err
+		}
+		if code.Code == stun.CodeRoleConflict {
+			return mapped, ErrRoleConflict
+		}
+		return mapped, stun.ResponseErr{Code: code}
+	default:
+		return mapped, ErrUnexpectedResponse
+	}
+}
@@ -0,0 +1,237 @@
+package ice
+
+import (
+	"net"
+	"testing"
+
+	"gortc.io/stun"
+)
+
+var (
+	testLocal  = Credentials{Ufrag: "lfrag", Pwd: "local-password-123456"}
+	testRemote = Credentials{Ufrag: "rfrag", Pwd: "remote-password-123456"}
+)
+
+func TestRole_String(t *testing.T) {
+	for r, s := range map[Role]string{
+		RoleUnknown: "unknown",
+		Controlling: "controlling",
+		Controlled:  "controlled",
+	} {
+		if r.String() != s {
+			t.Errorf("%q (got) != %q (expected)", r, s)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := Check{
+		Local:        testLocal,
+		Remote:       testRemote,
+		Role:         Controlling,
+		TieBreaker:   42,
+		Priority:     1845501695,
+		UseCandidate: true,
+	}
+	m := new(stun.Message)
+	if err := c.Build(m); err != nil {
+		t.Fatal(err)
+	}
+	// Remote agent is receiving request.
+	req, err := ParseRequest(m, testRemote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Request{
+		RemoteUfrag:  "lfrag",
+		Role:         Controlling,
+		TieBreaker:   42,
+		Priority:     1845501695,
+		UseCandidate: true,
+	}
+	if *req != expected {
+		t.Errorf("%+v (got) != %+v (expected)", *req, expected)
+	}
+	if _, err = ParseRequest(m, testLocal); err != ErrBadUsername {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err = ParseRequest(m, Credentials{Ufrag: "rfrag", Pwd: "bad"}); !stun.IsIntegrityMismatch(err) {
+		t.Errorf("unexpected error %v", err)
+	}
+	t.Run("Response", func(t *testing.T) {
+		addr := stun.XORMappedAddress{IP: net.IPv4(127, 0, 0, 1), Port: 3478}
+		res := new(stun.Message)
+		if err := BuildSuccess(res, m, addr, testRemote); err != nil {
+			t.Fatal(err)
+		}
+		mapped, err := ParseResponse(res, testRemote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mapped.String() != addr.String() {
+			t.Errorf("bad mapped address %s", mapped)
+		}
+		if _, err = ParseResponse(res, testLocal); !stun.IsIntegrityMismatch(err) {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("RoleConflictResponse", func(t *testing.T) {
+		res := new(stun.Message)
+		if err := BuildError(res, m, stun.CodeRoleConflict, testRemote); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseResponse(res, testRemote); err != ErrRoleConflict {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("UnknownRole", func(t *testing.T) {
+		if err := (Check{}).Build(new(stun.Message)); err != ErrUnknownRole {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestParseRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		setters []stun.Setter
+		err     error
+		code    stun.ErrorCode
+	}{
+		{
+			name:    "NotRequest",
+			setters: []stun.Setter{stun.BindingSuccess, stun.Fingerprint},
+			err:     ErrNotBindingRequest,
+		},
+		{
+			name:    "NoFingerprint",
+			setters: []stun.Setter{stun.BindingRequest},
+			err:     ErrNoFingerprint,
+		},
+		{
+			name:    "NoUsername",
+			setters: []stun.Setter{stun.BindingRequest, stun.Fingerprint},
+			err:     ErrNoUsername,
+			code:    stun.CodeBadRequest,
+		},
+		{
+			name: "NoIntegrity",
+			setters: []stun.Setter{stun.BindingRequest,
+				stun.NewUsername("rfrag:lfrag"), stun.Fingerprint,
+			},
+			err:  ErrNoIntegrity,
+			code: stun.CodeBadRequest,
+		},
+		{
+			name: "NoColon",
+			setters: []stun.Setter{stun.BindingRequest,
+				stun.NewUsername("rfrag"), stun.NewShortTermIntegrity(testRemote.Pwd), stun.Fingerprint,
+			},
+			err:  ErrBadUsername,
+			code: stun.CodeUnauthorized,
+		},
+		{
+			name: "NoPriority",
+			setters: []stun.Setter{stun.BindingRequest,
+				stun.NewUsername("rfrag:lfrag"), stun.ICEControlling(1),
+				stun.NewShortTermIntegrity(testRemote.Pwd), stun.Fingerprint,
+			},
+			err:  ErrNoPriority,
+			code: stun.CodeBadRequest,
+		},
+		{
+			name: "NoRole",
+			setters: []stun.Setter{stun.BindingRequest,
+				stun.NewUsername("rfrag:lfrag"), stun.Priority(1),
+				stun.NewShortTermIntegrity(testRemote.Pwd), stun.Fingerprint,
+			},
+			err:  ErrNoRole,
+			code: stun.CodeBadRequest,
+		},
+		{
+			name: "BothRoles",
+			setters: []stun.Setter{stun.BindingRequest,
+				stun.NewUsername("rfrag:lfrag"), stun.Priority(1),
+				stun.ICEControlling(1), stun.ICEControlled(1),
+				stun.NewShortTermIntegrity(testRemote.Pwd), stun.Fingerprint,
+			},
+			err:  ErrBothRoles,
+			code: stun.CodeBadRequest,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := stun.MustBuild(append([]stun.Setter{stun.TransactionID}, tc.setters...)...)
+			_, err := ParseRequest(m, testRemote)
+			if err != tc.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if code := ErrorCode(err); code != tc.code {
+				t.Errorf("code %d (got) != %d (expected)", code, tc.code)
+			}
+		})
+	}
+}
+
+func TestResolveRoleConflict(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		role       Role
+		tieBreaker uint64
+		req        Request
+		out        Role
+		err        error
+	}{
+		{"NoConflict", Controlling, 1, Request{Role: Controlled, TieBreaker: 2}, Controlling, nil},
+		{"ControllingWins", Controlling, 2, Request{Role: Controlling, TieBreaker: 1}, Controlling, ErrRoleConflict},
+		{"ControllingEqual", Controlling, 2, Request{Role: Controlling, TieBreaker: 2}, Controlling, ErrRoleConflict},
+		{"ControllingLoses", Controlling, 1, Request{Role: Controlling, TieBreaker: 2}, Controlled, nil},
+		{"ControlledSwitches", Controlled, 2, Request{Role: Controlled, TieBreaker: 1}, Controlling, nil},
+		{"ControlledLoses", Controlled, 1, Request{Role: Controlled, TieBreaker: 2}, Controlled, ErrRoleConflict},
+		{"Unknown", RoleUnknown, 1, Request{}, RoleUnknown, ErrUnknownRole},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			role, err := ResolveRoleConflict(tc.role, tc.tieBreaker, &tc.req)
+			if err != tc.err {
+				t.Errorf("unexpected error %v", err)
+			}
+			if role != tc.out {
+				t.Errorf("%s (got) != %s (expected)", role, tc.out)
+			}
+		})
+	}
+	if ErrorCode(ErrRoleConflict) != stun.CodeRoleConflict {
+		t.Error("bad code for role conflict")
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	req := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	t.Run("Unauthorized", func(t *testing.T) {
+		res := new(stun.Message)
+		if err := BuildError(res, req, stun.CodeUnauthorized, testRemote); err != nil {
+			t.Fatal(err)
+		}
+		if res.Contains(stun.AttrMessageIntegrity) {
+			t.Error("401 response should not be authenticated")
+		}
+		if _, err := ParseResponse(res, testRemote); err != stun.ErrAttributeNotFound {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("ServerError", func(t *testing.T) {
+		res := new(stun.Message)
+		if err := BuildError(res, req, stun.CodeServerError, testRemote); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ParseResponse(res, testRemote)
+		if respErr, ok := err.(stun.ResponseErr); !ok || respErr.Code.Code != stun.CodeServerError {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Unexpected", func(t *testing.T) {
+		res := stun.MustBuild(req, stun.NewType(stun.MethodAllocate, stun.ClassSuccessResponse))
+		if _, err := ParseResponse(res, testRemote); err != ErrUnexpectedResponse {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
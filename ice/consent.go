@@ -0,0 +1,224 @@
+package ice
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"gortc.io/stun"
+	"gortc.io/stun/internal/clockutil"
+)
+
+// Default consent freshness parameters.
+//
+// RFC 7675 Section 5.1
+const (
+	DefaultConsentInterval = time.Second * 5
+	DefaultConsentTimeout  = time.Second * 30
+	// DefaultConsentRTO is RTO of consent check transaction.
+	DefaultConsentRTO = time.Millisecond * 500
+	// DefaultConsentAttempts is maximum number of consent check
+	// retransmissions.
+	DefaultConsentAttempts = 2
+)
+
+// ConsentOption sets some Consent option.
+type ConsentOption func(c *Consent)
+
+// WithConsentClock sets Clock of consent monitor, the source of current
+// time.
+func WithConsentClock(clock stun.Clock) ConsentOption {
+	return func(c *Consent) {
+		c.clock = clock
+	}
+}
+
+// WithConsentCollector sets collector that drives consent monitor, which
+// is ticker with DefaultConsentRate by default.
+func WithConsentCollector(coll stun.Collector) ConsentOption {
+	return func(c *Consent) {
+		c.collector = coll
+	}
+}
+
+// WithConsentInterval sets mean interval between consent checks, actual
+// intervals are randomized in range from 0.8 to 1.2 of it.
+func WithConsentInterval(d time.Duration) ConsentOption {
+	return func(c *Consent) {
+		c.interval = d
+	}
+}
+
+// WithConsentTimeout sets duration after last authenticated response when
+// consent is lost.
+func WithConsentTimeout(d time.Duration) ConsentOption {
+	return func(c *Consent) {
+		c.timeout = d
+	}
+}
+
+// WithConsentTransaction sets RTO and maximum number of retransmissions of
+// consent check transactions.
+func WithConsentTransaction(rto time.Duration, attempts int) ConsentOption {
+	return func(c *Consent) {
+		c.rto = rto
+		c.attempts = attempts
+	}
+}
+
+// DefaultConsentRate is rate of default consent monitor ticker.
+const DefaultConsentRate = time.Millisecond * 100
+
+// Consent is consent freshness monitor that periodically sends consent
+// checks over established candidate pair using Client and signals consent
+// loss if no authenticated success responses are received within timeout.
+// Application must stop sending on pair when consent is lost.
+//
+// RFC 7675
+type Consent struct {
+	client    *stun.Client
+	check     Check
+	clock     stun.Clock
+	collector stun.Collector
+	interval  time.Duration
+	timeout   time.Duration
+	rto       time.Duration
+	attempts  int
+
+	mux    sync.Mutex
+	rand   *rand.Rand
+	next   time.Time // time of next check
+	last   time.Time // time of last authenticated response
+	lost   chan struct{}
+	closed bool
+}
+
+// NewConsent starts consent freshness monitor that sends checks described
+// by check using client, which should be connected to remote candidate of
+// pair. The UseCandidate field of check is ignored. Call Close to stop
+// monitor, the client is not closed.
+func NewConsent(client *stun.Client, check Check, options ...ConsentOption) (*Consent, error) {
+	if _, err := check.Role.attr(check.TieBreaker); err != nil {
+		return nil, err
+	}
+	check.UseCandidate = false
+	c := &Consent{
+		client:   client,
+		check:    check,
+		clock:    clockutil.System{},
+		interval: DefaultConsentInterval,
+		timeout:  DefaultConsentTimeout,
+		rto:      DefaultConsentRTO,
+		attempts: DefaultConsentAttempts,
+		lost:     make(chan struct{}),
+	}
+	for _, o := range options {
+		o(c)
+	}
+	if c.collector == nil {
+		c.collector = clockutil.NewCollector(c.clock)
+	}
+	now := c.clock.Now()
+	c.rand = rand.New(rand.NewSource(now.UnixNano()))
+	c.last = now
+	c.next = now.Add(c.nextInterval())
+	if err := c.collector.Start(DefaultConsentRate, c.collect); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// nextInterval returns randomized interval to next check.
+//
+// RFC 7675 Section 5.1
+func (c *Consent) nextInterval() time.Duration {
+	return c.interval*8/10 + time.Duration(c.rand.Int63n(int64(c.interval*4/10)+1))
+}
+
+func (c *Consent) collect(now time.Time) {
+	c.mux.Lock()
+	if c.closed || !c.isValid() {
+		c.mux.Unlock()
+		return
+	}
+	if now.Sub(c.last) >= c.timeout {
+		close(c.lost)
+		c.mux.Unlock()
+		return
+	}
+	if now.Before(c.next) {
+		c.mux.Unlock()
+		return
+	}
+	c.next = now.Add(c.nextInterval())
+	c.mux.Unlock()
+	m := new(stun.Message)
+	if err := c.check.Build(m); err != nil {
+		return
+	}
+	// Errors are ignored, consent will be lost if no responses are
+	// received.
+	_ = c.client.StartWithOptions(m, c.handle,
+		stun.WithTransactionRTO(c.rto), stun.WithMaxAttempts(c.attempts),
+	)
+}
+
+func (c *Consent) handle(e stun.Event) {
+	if e.Error != nil {
+		return
+	}
+	if _, err := ParseResponse(e.Message, c.check.Remote); err != nil {
+		return
+	}
+	now := c.clock.Now()
+	c.mux.Lock()
+	if c.isValid() && now.After(c.last) {
+		c.last = now
+	}
+	c.mux.Unlock()
+}
+
+func (c *Consent) isValid() bool {
+	select {
+	case <-c.lost:
+		return false
+	default:
+		return true
+	}
+}
+
+// Lost returns channel that is closed when consent is lost.
+func (c *Consent) Lost() <-chan struct{} {
+	return c.lost
+}
+
+// Valid reports whether consent is not lost yet.
+func (c *Consent) Valid() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.isValid()
+}
+
+// LastResponse returns time of last authenticated success response or time
+// of monitor start if no responses are received yet.
+func (c *Consent) LastResponse() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.last
+}
+
+// ErrConsentClosed means that consent monitor is already closed.
+var ErrConsentClosed = errors.New("consent monitor is closed")
+
+// Close stops consent monitor.
+func (c *Consent) Close() error {
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+		return ErrConsentClosed
+	}
+	c.closed = true
+	c.mux.Unlock()
+	return c.collector.Close()
+}
@@ -0,0 +1,199 @@
+package ice
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+type manualClock struct {
+	mux     sync.Mutex
+	current time.Time
+}
+
+func (m *manualClock) Add(d time.Duration) time.Time {
+	m.mux.Lock()
+	v := m.current.Add(d)
+	m.current = v
+	m.mux.Unlock()
+	return v
+}
+
+func (m *manualClock) Now() time.Time {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.current
+}
+
+type manualCollector struct {
+	f func(now time.Time)
+}
+
+func (m *manualCollector) Start(rate time.Duration, f func(now time.Time)) error {
+	m.f = f
+	return nil
+}
+
+func (m *manualCollector) Close() error { return nil }
+
+// countingConn counts packets read from underlying connection.
+type countingConn struct {
+	net.PacketConn
+	reads int32
+}
+
+func (c *countingConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil {
+		atomic.AddInt32(&c.reads, 1)
+	}
+	return n, addr, err
+}
+
+// startConsent starts Lite agent with local credentials and consent
+// monitor of pair to it.
+func startConsent(t *testing.T, local Credentials) (*Consent, *countingConn, *manualClock, *manualCollector, func()) {
+	t.Helper()
+	conn := &countingConn{PacketConn: listenUDP(t, "127.0.0.1:0")}
+	go func() {
+		_ = NewLite(conn, local).Serve()
+	}()
+	clientConn, err := net.Dial("udp4", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := stun.NewClient(clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		clock     = &manualClock{current: time.Now()}
+		collector = new(manualCollector)
+	)
+	c, err := NewConsent(client, Check{
+		Local:      testLocal,
+		Remote:     testRemote,
+		Role:       Controlling,
+		TieBreaker: 1,
+		Priority:   1,
+	}, WithConsentClock(clock), WithConsentCollector(collector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, conn, clock, collector, func() {
+		if err := c.Close(); err != nil {
+			t.Error(err)
+		}
+		if err := c.Close(); err != ErrConsentClosed {
+			t.Error("second close should fail")
+		}
+		if err := client.Close(); err != nil {
+			t.Error(err)
+		}
+		if err := conn.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func waitReads(t *testing.T, conn *countingConn, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for atomic.LoadInt32(&conn.reads) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d reads", n)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestConsent(t *testing.T) {
+	c, conn, clock, collector, stop := startConsent(t, testRemote)
+	defer stop()
+	start := clock.Now()
+	collector.f(clock.Add(time.Second * 3))
+	if reads := atomic.LoadInt32(&conn.reads); reads != 0 {
+		t.Fatalf("unexpected %d checks before 4s", reads)
+	}
+	now := clock.Add(time.Second * 3)
+	collector.f(now)
+	waitReads(t, conn, 1)
+	deadline := time.Now().Add(time.Second * 5)
+	for !c.LastResponse().Equal(now) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for response")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	if c.LastResponse().Equal(start) {
+		t.Fatal("consent should be refreshed")
+	}
+	// Next check is not sent before 4s after previous one.
+	collector.f(clock.Add(time.Second * 3))
+	if reads := atomic.LoadInt32(&conn.reads); reads != 1 {
+		t.Fatalf("unexpected %d checks", reads)
+	}
+	// Consent is refreshed by next check.
+	now = clock.Add(time.Second * 26)
+	collector.f(now)
+	waitReads(t, conn, 2)
+	deadline = time.Now().Add(time.Second * 5)
+	for !c.LastResponse().Equal(now) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for response")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	collector.f(clock.Add(time.Second * 29))
+	if !c.Valid() {
+		t.Fatal("consent should be valid within timeout")
+	}
+}
+
+func TestConsent_Unauthenticated(t *testing.T) {
+	c, conn, clock, collector, stop := startConsent(t, Credentials{
+		Ufrag: testRemote.Ufrag, Pwd: "other-password-1234567",
+	})
+	defer stop()
+	start := clock.Now()
+	for i := int32(1); i <= 4; i++ {
+		collector.f(clock.Add(time.Second * 6))
+		waitReads(t, conn, i)
+	}
+	// Waiting for possible response to last check.
+	time.Sleep(time.Millisecond * 50)
+	collector.f(clock.Add(time.Second * 5))
+	if !c.LastResponse().Equal(start) {
+		t.Error("consent should not be refreshed by 401 responses")
+	}
+	if !c.Valid() {
+		t.Fatal("consent should be valid within timeout")
+	}
+	collector.f(clock.Add(time.Second))
+	select {
+	case <-c.Lost():
+	default:
+		t.Fatal("consent should be lost")
+	}
+	if c.Valid() {
+		t.Error("consent should not be valid")
+	}
+}
+
+func TestNewConsent(t *testing.T) {
+	if _, err := NewConsent(nil, Check{}); err != ErrUnknownRole {
+		t.Errorf("unexpected error %v", err)
+	}
+	c := &Consent{interval: DefaultConsentInterval}
+	c.rand = rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if d := c.nextInterval(); d < time.Second*4 || d > time.Second*6 {
+			t.Fatalf("interval %s out of range", d)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package ice
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"gortc.io/stun"
+)
+
+// LiteOption sets some Lite agent option.
+type LiteOption func(l *Lite)
+
+// WithSelectionHandler sets handler that is called when selected remote
+// address for remote ufrag is changed. Handler is called from goroutine
+// that processes requests, so it should not block.
+func WithSelectionHandler(h func(remoteUfrag string, addr net.Addr)) LiteOption {
+	return func(l *Lite) {
+		l.onSelect = h
+	}
+}
+
+// selection is nominated pair with highest priority for remote ufrag.
+type selection struct {
+	addr     net.Addr
+	priority stun.Priority
+}
+
+// Lite is ICE-lite agent that responds to connectivity checks received on
+// PacketConn using short-term credentials. Lite agent is always controlled
+// and has only host candidates, so the remote full agent checks and
+// nominates pairs, while Lite agent tracks nominated pairs and reports
+// selected remote address per remote ufrag.
+//
+// RFC 8445 Section 2.5
+type Lite struct {
+	conn     net.PacketConn
+	local    Credentials
+	onSelect func(remoteUfrag string, addr net.Addr)
+
+	mux      sync.Mutex
+	selected map[string]selection
+}
+
+// NewLite initializes and returns new Lite agent that responds on conn
+// using local credentials. Call Serve to start serving or pass received
+// packets to Process.
+func NewLite(conn net.PacketConn, local Credentials, options ...LiteOption) *Lite {
+	l := &Lite{
+		conn:     conn,
+		local:    local,
+		selected: make(map[string]selection),
+	}
+	for _, o := range options {
+		o(l)
+	}
+	return l
+}
+
+// Serve reads packets from conn and processes them until conn is closed,
+// returning read error. Packets that are not connectivity checks are
+// discarded.
+func (l *Lite) Serve() error {
+	var (
+		buf = make([]byte, 1500)
+		req = new(stun.Message)
+		res = new(stun.Message)
+	)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		// Errors are ignored, the remote agent will retransmit the check.
+		_ = l.process(buf[:n], addr, req, res)
+	}
+}
+
+// ErrNotSTUN means that packet is not a STUN message.
+var ErrNotSTUN = errors.New("not a STUN message")
+
+// Process processes packet b received from addr, writing response to conn
+// if required. Returns ErrNotSTUN if b is not a STUN message, so it can be
+// demultiplexed by the caller.
+func (l *Lite) Process(b []byte, addr net.Addr) error {
+	return l.process(b, addr, new(stun.Message), new(stun.Message))
+}
+
+// errNotUDP means that request is received not from UDP address, so it
+// can't be mapped to XOR-MAPPED-ADDRESS.
+var errNotUDP = errors.New("not an UDP address")
+
+func (l *Lite) process(b []byte, addr net.Addr, req, res *stun.Message) error {
+	if !stun.IsMessage(b) {
+		return ErrNotSTUN
+	}
+	req.Raw = append(req.Raw[:0], b...)
+	if err := req.Decode(); err != nil {
+		return err
+	}
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return errNotUDP
+	}
+	r, err := ParseRequest(req, l.local)
+	if err == nil && r.Role != Controlling {
+		// Lite agent is always controlled, so remote agent must
+		// switch role.
+		//
+		// RFC 8445 Section 6.1.1
+		err = ErrRoleConflict
+	}
+	if err != nil {
+		code := ErrorCode(err)
+		if code == 0 {
+			return err
+		}
+		if buildErr := BuildError(res, req, code, l.local); buildErr != nil {
+			return buildErr
+		}
+		_, writeErr := l.conn.WriteTo(res.Raw, addr)
+		if writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+	mapped := stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port}
+	if err = BuildSuccess(res, req, mapped, l.local); err != nil {
+		return err
+	}
+	if _, err = l.conn.WriteTo(res.Raw, addr); err != nil {
+		return err
+	}
+	if r.UseCandidate {
+		l.nominate(r, addr)
+	}
+	return nil
+}
+
+// nominate nominates pair with remote address addr, selecting it if it has
+// highest priority among nominated pairs.
+//
+// RFC 8445 Section 7.3.1.5
+func (l *Lite) nominate(r *Request, addr net.Addr) {
+	l.mux.Lock()
+	current, ok := l.selected[r.RemoteUfrag]
+	if ok && current.priority > r.Priority {
+		l.mux.Unlock()
+		return
+	}
+	l.selected[r.RemoteUfrag] = selection{addr: addr, priority: r.Priority}
+	l.mux.Unlock()
+	changed := !ok || current.addr.String() != addr.String()
+	if changed && l.onSelect != nil {
+		l.onSelect(r.RemoteUfrag, addr)
+	}
+}
+
+// Selected returns selected remote address for remote ufrag or nil if no
+// pair is nominated yet.
+func (l *Lite) Selected(remoteUfrag string) net.Addr {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.selected[remoteUfrag].addr
+}
+
+// Forget removes selected address for remote ufrag, for example on ICE
+// restart or when session is ended.
+func (l *Lite) Forget(remoteUfrag string) {
+	l.mux.Lock()
+	delete(l.selected, remoteUfrag)
+	l.mux.Unlock()
+}
@@ -0,0 +1,161 @@
+package ice
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+func listenUDP(t *testing.T, address string) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// doCheck sends check from conn to addr and returns response.
+func doCheck(t *testing.T, conn net.PacketConn, addr net.Addr, c Check) *stun.Message {
+	t.Helper()
+	req := new(stun.Message)
+	if err := c.Build(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteTo(req.Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := new(stun.Message)
+	if _, err = res.Write(buf[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if res.TransactionID != req.TransactionID {
+		t.Fatal("transaction id mismatch")
+	}
+	return res
+}
+
+func TestLite(t *testing.T) {
+	conn := listenUDP(t, "127.0.0.1:0")
+	selected := make(chan net.Addr, 10)
+	lite := NewLite(conn, testRemote, WithSelectionHandler(func(ufrag string, addr net.Addr) {
+		if ufrag != testLocal.Ufrag {
+			t.Errorf("unexpected ufrag %q", ufrag)
+		}
+		selected <- addr
+	}))
+	served := make(chan error, 1)
+	go func() {
+		served <- lite.Serve()
+	}()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; err == nil {
+			t.Error("serve should return error after close")
+		}
+	}()
+	var (
+		first  = listenUDP(t, "127.0.0.1:0")
+		second = listenUDP(t, "127.0.0.1:0")
+	)
+	defer first.Close()
+	defer second.Close()
+	check := Check{
+		Local:      testLocal,
+		Remote:     testRemote,
+		Role:       Controlling,
+		TieBreaker: 1,
+		Priority:   Priority(PeerReflexive.Preference(), 100, 1),
+	}
+	t.Run("Check", func(t *testing.T) {
+		mapped, err := ParseResponse(doCheck(t, first, conn.LocalAddr(), check), testRemote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local := first.LocalAddr().(*net.UDPAddr)
+		if !mapped.IP.Equal(local.IP) || mapped.Port != local.Port {
+			t.Errorf("bad mapped address %s", mapped)
+		}
+		if lite.Selected(testLocal.Ufrag) != nil {
+			t.Error("should not be selected without nomination")
+		}
+	})
+	t.Run("Nomination", func(t *testing.T) {
+		nominate := check
+		nominate.UseCandidate = true
+		if _, err := ParseResponse(doCheck(t, first, conn.LocalAddr(), nominate), testRemote); err != nil {
+			t.Fatal(err)
+		}
+		if addr := <-selected; addr.String() != first.LocalAddr().String() {
+			t.Errorf("bad selected address %s", addr)
+		}
+		// Lower priority nomination is not selected.
+		nominate.Priority--
+		if _, err := ParseResponse(doCheck(t, second, conn.LocalAddr(), nominate), testRemote); err != nil {
+			t.Fatal(err)
+		}
+		if addr := lite.Selected(testLocal.Ufrag); addr.String() != first.LocalAddr().String() {
+			t.Errorf("bad selected address %s", addr)
+		}
+		// Higher priority nomination is selected.
+		nominate.Priority += 2
+		if _, err := ParseResponse(doCheck(t, second, conn.LocalAddr(), nominate), testRemote); err != nil {
+			t.Fatal(err)
+		}
+		if addr := <-selected; addr.String() != second.LocalAddr().String() {
+			t.Errorf("bad selected address %s", addr)
+		}
+		lite.Forget(testLocal.Ufrag)
+		if lite.Selected(testLocal.Ufrag) != nil {
+			t.Error("should be forgotten")
+		}
+	})
+	t.Run("RoleConflict", func(t *testing.T) {
+		controlled := check
+		controlled.Role = Controlled
+		if _, err := ParseResponse(doCheck(t, first, conn.LocalAddr(), controlled), testRemote); err != ErrRoleConflict {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		bad := check
+		bad.Remote.Pwd = "bad-password-123456789"
+		res := doCheck(t, first, conn.LocalAddr(), bad)
+		var code stun.ErrorCodeAttribute
+		if err := code.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if code.Code != stun.CodeUnauthorized {
+			t.Errorf("unexpected code %s", code)
+		}
+	})
+}
+
+func TestLite_Process(t *testing.T) {
+	conn := listenUDP(t, "127.0.0.1:0")
+	defer conn.Close()
+	lite := NewLite(conn, testRemote)
+	from := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	if err := lite.Process([]byte{1, 2, 3}, from); err != ErrNotSTUN {
+		t.Errorf("unexpected error %v", err)
+	}
+	m := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	if err := lite.Process(m.Raw, from); err != ErrNoFingerprint {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := lite.Process(m.Raw, &net.TCPAddr{}); err != errNotUDP {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package stun
+
+// Priority represents PRIORITY attribute.
+//
+// The PRIORITY attribute indicates the priority that is to be associated
+// with a peer-reflexive candidate, if one will be discovered by this check.
+//
+// RFC 8445 Section 7.1.1
+type Priority uint32
+
+const prioritySize = 4 // 32 bit
+
+// AddTo adds PRIORITY attribute to message.
+func (p Priority) AddTo(m *Message) error {
+	var v [prioritySize]byte
+	bin.PutUint32(v[:], uint32(p))
+	m.Add(AttrPriority, v[:])
+	return nil
+}
+
+// GetFrom decodes PRIORITY from message.
+func (p *Priority) GetFrom(m *Message) error {
+	v, err := m.Get(AttrPriority)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrPriority, len(v), prioritySize); err != nil {
+		return err
+	}
+	*p = Priority(bin.Uint32(v))
+	return nil
+}
+
+// UseCandidateAttr represents USE-CANDIDATE attribute.
+//
+// The controlling agent includes USE-CANDIDATE attribute to nominate
+// the candidate pair. The attribute has no content.
+//
+// RFC 8445 Section 7.1.2
+type UseCandidateAttr struct{}
+
+// UseCandidate is shorthand for UseCandidateAttr.
+var UseCandidate UseCandidateAttr
+
+// AddTo adds USE-CANDIDATE attribute to message.
+func (UseCandidateAttr) AddTo(m *Message) error {
+	m.Add(AttrUseCandidate, nil)
+	return nil
+}
+
+// GetFrom returns nil if message contains valid USE-CANDIDATE attribute.
+func (UseCandidateAttr) GetFrom(m *Message) error {
+	v, err := m.Get(AttrUseCandidate)
+	if err != nil {
+		return err
+	}
+	return CheckSize(AttrUseCandidate, len(v), 0)
+}
+
+// IsSet reports whether message contains USE-CANDIDATE attribute.
+func (UseCandidateAttr) IsSet(m *Message) bool {
+	return m.Contains(AttrUseCandidate)
+}
+
+const tieBreakerSize = 8 // 64 bit
+
+// ICEControlled represents ICE-CONTROLLED attribute with the 64-bit
+// tie-breaker value.
+//
+// The ICE-CONTROLLED attribute is present in a Binding request and
+// indicates that the client believes it is currently in the controlled role.
+//
+// RFC 8445 Section 7.1.3
+type ICEControlled uint64
+
+// AddTo adds ICE-CONTROLLED attribute to message.
+func (c ICEControlled) AddTo(m *Message) error {
+	return addTieBreaker(m, AttrICEControlled, uint64(c))
+}
+
+// GetFrom decodes ICE-CONTROLLED from message.
+func (c *ICEControlled) GetFrom(m *Message) error {
+	return getTieBreaker(m, AttrICEControlled, (*uint64)(c))
+}
+
+// ICEControlling represents ICE-CONTROLLING attribute with the 64-bit
+// tie-breaker value.
+//
+// The ICE-CONTROLLING attribute is present in a Binding request and
+// indicates that the client believes it is currently in the controlling role.
+//
+// RFC 8445 Section 7.1.3
+type ICEControlling uint64
+
+// AddTo adds ICE-CONTROLLING attribute to message.
+func (c ICEControlling) AddTo(m *Message) error {
+	return addTieBreaker(m, AttrICEControlling, uint64(c))
+}
+
+// GetFrom decodes ICE-CONTROLLING from message.
+func (c *ICEControlling) GetFrom(m *Message) error {
+	return getTieBreaker(m, AttrICEControlling, (*uint64)(c))
+}
+
+func addTieBreaker(m *Message, t AttrType, tieBreaker uint64) error {
+	var v [tieBreakerSize]byte
+	bin.PutUint64(v[:], tieBreaker)
+	m.Add(t, v[:])
+	return nil
+}
+
+func getTieBreaker(m *Message, t AttrType, tieBreaker *uint64) error {
+	v, err := m.Get(t)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(t, len(v), tieBreakerSize); err != nil {
+		return err
+	}
+	*tieBreaker = bin.Uint64(v)
+	return nil
+}
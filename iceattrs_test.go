@@ -0,0 +1,140 @@
+package stun
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	m := new(Message)
+	p := Priority(0x6e0001ff)
+	if err := p.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Get(AttrPriority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{0x6e, 0x00, 0x01, 0xff}) {
+		t.Errorf("unexpected value %x", v)
+	}
+	var got Priority
+	if err = got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != p {
+		t.Errorf("%d (got) != %d (expected)", got, p)
+	}
+	t.Run("Not found", func(t *testing.T) {
+		if err := got.GetFrom(new(Message)); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrPriority, []byte{1, 2, 3, 4, 5})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestUseCandidate(t *testing.T) {
+	m := new(Message)
+	if UseCandidate.IsSet(m) {
+		t.Error("should not be set")
+	}
+	if err := UseCandidate.GetFrom(m); err != ErrAttributeNotFound {
+		t.Error("should be not found: ", err)
+	}
+	if err := UseCandidate.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	if !UseCandidate.IsSet(m) {
+		t.Error("should be set")
+	}
+	if err := UseCandidate.GetFrom(m); err != nil {
+		t.Error(err)
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrUseCandidate, []byte{1, 2, 3, 4})
+		if err := UseCandidate.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestICEControl(t *testing.T) {
+	const tieBreaker = 0x0102030405060708
+	t.Run("Controlled", func(t *testing.T) {
+		m := new(Message)
+		if err := ICEControlled(tieBreaker).AddTo(m); err != nil {
+			t.Fatal(err)
+		}
+		v, err := m.Get(AttrICEControlled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(v, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+			t.Errorf("unexpected value %x", v)
+		}
+		var got ICEControlled
+		if err = got.GetFrom(m); err != nil {
+			t.Fatal(err)
+		}
+		if got != tieBreaker {
+			t.Errorf("%x (got) != %x (expected)", uint64(got), uint64(tieBreaker))
+		}
+		if err = new(ICEControlling).GetFrom(m); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+	t.Run("Controlling", func(t *testing.T) {
+		m := new(Message)
+		if err := ICEControlling(tieBreaker).AddTo(m); err != nil {
+			t.Fatal(err)
+		}
+		var got ICEControlling
+		if err := got.GetFrom(m); err != nil {
+			t.Fatal(err)
+		}
+		if got != tieBreaker {
+			t.Errorf("%x (got) != %x (expected)", uint64(got), uint64(tieBreaker))
+		}
+		if err := new(ICEControlled).GetFrom(m); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrICEControlling, []byte{1, 2, 3, 4})
+		if err := new(ICEControlling).GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func BenchmarkPriority_AddTo(b *testing.B) {
+	b.ReportAllocs()
+	m := new(Message)
+	p := Priority(0x6e0001ff)
+	for i := 0; i < b.N; i++ {
+		if err := p.AddTo(m); err != nil {
+			b.Fatal(err)
+		}
+		m.Reset()
+	}
+}
+
+func BenchmarkICEControlling_AddTo(b *testing.B) {
+	b.ReportAllocs()
+	m := new(Message)
+	c := ICEControlling(0x0102030405060708)
+	for i := 0; i < b.N; i++ {
+		if err := c.AddTo(m); err != nil {
+			b.Fatal(err)
+		}
+		m.Reset()
+	}
+}
@@ -14,7 +14,8 @@ import (
 const credentialsSep = ":"
 
 // NewLongTermIntegrity returns new MessageIntegrity with key for long-term
-// credentials. Password, username, and realm must be SASL-prepared.
+// credentials. Password, username, and realm must be SASL-prepared, see
+// NewLongTermIntegrityPrep.
 func NewLongTermIntegrity(username, realm, password string) MessageIntegrity {
 	k := strings.Join([]string{username, realm, password}, credentialsSep)
 	// #nosec
@@ -29,6 +30,29 @@ func NewShortTermIntegrity(password string) MessageIntegrity {
 	return MessageIntegrity(password)
 }
 
+// NewLongTermIntegrityPrep is like NewLongTermIntegrity, but prepares
+// username, realm and password with SASLprep, returning error if any of
+// them is not valid.
+func NewLongTermIntegrityPrep(username, realm, password string) (MessageIntegrity, error) {
+	var err error
+	for _, s := range []*string{&username, &realm, &password} {
+		if *s, err = SASLprep(*s); err != nil {
+			return nil, err
+		}
+	}
+	return NewLongTermIntegrity(username, realm, password), nil
+}
+
+// NewShortTermIntegrityPrep is like NewShortTermIntegrity, but prepares
+// password with SASLprep, returning error if it is not valid.
+func NewShortTermIntegrityPrep(password string) (MessageIntegrity, error) {
+	password, err := SASLprep(password)
+	if err != nil {
+		return nil, err
+	}
+	return NewShortTermIntegrity(password), nil
+}
+
 // MessageIntegrity represents MESSAGE-INTEGRITY attribute.
 //
 // AddTo and Check methods are using zero-allocation version of hmac, see
@@ -91,15 +115,28 @@ var ErrIntegrityMismatch = errors.New("integrity check failed")
 //
 // CPU costly, see BenchmarkMessageIntegrity_Check.
 func (i MessageIntegrity) Check(m *Message) error {
-	v, err := m.Get(AttrMessageIntegrity)
+	v, b, err := integrityInput(m)
 	if err != nil {
 		return err
 	}
+	expected := newHMAC(i, b, m.Raw[len(m.Raw):])
+	m.WriteLength() // writing length back
+	return checkHMAC(v, expected)
+}
 
-	// Adjusting length in header to match m.Raw that was
-	// used when computing HMAC.
+// integrityInput returns value of MESSAGE-INTEGRITY attribute and data
+// before it, that is input of HMAC. Length in header is adjusted to match
+// m.Raw that was used when computing HMAC, so it must be written back with
+// m.WriteLength.
+func integrityInput(m *Message) (v, b []byte, err error) {
+	v, err = m.Get(AttrMessageIntegrity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(v) != messageIntegritySize {
+		return nil, nil, ErrIntegrityMismatch
+	}
 	var (
-		length         = m.Length
 		afterIntegrity = false
 		sizeReduced    int
 	)
@@ -112,13 +149,90 @@ func (i MessageIntegrity) Check(m *Message) error {
 			afterIntegrity = true
 		}
 	}
-	m.Length -= uint32(sizeReduced)
-	m.WriteLength()
+	length := int(m.Length) - sizeReduced
+	bin.PutUint16(m.Raw[2:4], uint16(length))
 	// startOfHMAC should be first byte of integrity attribute.
-	startOfHMAC := messageHeaderSize + m.Length - (attributeHeaderSize + messageIntegritySize)
-	b := m.Raw[:startOfHMAC] // data before integrity attribute
-	expected := newHMAC(i, b, m.Raw[len(m.Raw):])
-	m.Length = length
+	startOfHMAC := messageHeaderSize + length - (attributeHeaderSize + messageIntegritySize)
+	return v, m.Raw[:startOfHMAC], nil
+}
+
+// KeyedIntegrity is MessageIntegrity with precomputed HMAC key, that is
+// derived once instead of for each message. AddTo and Check do not
+// allocate. Safe for concurrent use.
+//
+// Use it for keys that are used for many messages, e.g. server keys.
+type KeyedIntegrity struct {
+	key *hmac.SHA1Key
+}
+
+// NewKeyedIntegrity returns new KeyedIntegrity with key of i.
+func NewKeyedIntegrity(i MessageIntegrity) *KeyedIntegrity {
+	return &KeyedIntegrity{key: hmac.NewSHA1Key(i)}
+}
+
+// sum writes HMAC of message to dst, that has messageIntegritySize length.
+func (k *KeyedIntegrity) sum(dst, message []byte) {
+	mac := hmac.AcquireSHA1Key(k.key)
+	writeOrPanic(mac, message)
+	mac.Sum(dst[:0])
+	hmac.PutSHA1(mac)
+}
+
+// AddTo adds MESSAGE-INTEGRITY attribute to message.
+func (k *KeyedIntegrity) AddTo(m *Message) error {
+	for _, a := range m.Attributes {
+		// Message should not contain FINGERPRINT attribute
+		// before MESSAGE-INTEGRITY.
+		if a.Type == AttrFingerprint {
+			return ErrFingerprintBeforeIntegrity
+		}
+	}
+	// Adding attribute with zero value to adjust length in header and
+	// computing HMAC in place of value, the input is message up to
+	// MESSAGE-INTEGRITY attribute.
+	var zero [messageIntegritySize]byte
+	m.Add(AttrMessageIntegrity, zero[:])
+	m.WriteLength()
+	start := len(m.Raw) - messageIntegritySize
+	k.sum(m.Raw[start:], m.Raw[:start-attributeHeaderSize])
+	return nil
+}
+
+// Check checks MESSAGE-INTEGRITY attribute.
+func (k *KeyedIntegrity) Check(m *Message) error {
+	v, b, err := integrityInput(m)
+	if err != nil {
+		return err
+	}
+	// Computing expected HMAC in place of actual value, which is restored
+	// after comparison.
+	var actual [messageIntegritySize]byte
+	copy(actual[:], v)
+	k.sum(v, b)
+	err = checkHMAC(actual[:], v)
+	copy(v, actual[:])
 	m.WriteLength() // writing length back
-	return checkHMAC(v, expected)
+	return err
+}
+
+// IntegrityKeys is set of MESSAGE-INTEGRITY keys that are accepted, e.g.
+// old and new keys during credentials rotation.
+type IntegrityKeys []MessageIntegrity
+
+// Check checks MESSAGE-INTEGRITY attribute against each key, returning
+// first key that matched, so response can be signed with it, or
+// ErrIntegrityMismatch if none matched. Length adjustment of message is
+// done once for all keys.
+func (k IntegrityKeys) Check(m *Message) (MessageIntegrity, error) {
+	v, b, err := integrityInput(m)
+	if err != nil {
+		return nil, err
+	}
+	defer m.WriteLength() // writing length back
+	for _, i := range k {
+		if hmac.Equal(v, newHMAC(i, b, m.Raw[len(m.Raw):])) {
+			return i, nil
+		}
+	}
+	return nil, ErrIntegrityMismatch
 }
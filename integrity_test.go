@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"testing"
+
+	"gortc.io/stun/internal/testutil"
 )
 
 func TestMessageIntegrity_AddTo_Simple(t *testing.T) {
@@ -88,6 +90,142 @@ func TestMessageIntegrityBeforeFingerprint(t *testing.T) {
 	}
 }
 
+func TestKeyedIntegrity(t *testing.T) {
+	i := NewLongTermIntegrity("user", "realm", "pass")
+	k := NewKeyedIntegrity(i)
+	expected := MustBuild(NewSoftware("software"), i)
+	m := MustBuild(NewSoftware("software"), k)
+	if !bytes.Equal(m.Raw, expected.Raw) {
+		t.Errorf("%x (got) != %x (expected)", m.Raw, expected.Raw)
+	}
+	if err := k.Check(m); err != nil {
+		t.Error(err)
+	}
+	t.Run("Fingerprint", func(t *testing.T) {
+		m := MustBuild(NewSoftware("software"), k, Fingerprint)
+		if err := k.Check(m); err != nil {
+			t.Error(err)
+		}
+		if err := k.AddTo(m); err != ErrFingerprintBeforeIntegrity {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		m := MustBuild(NewSoftware("software"), NewLongTermIntegrity("user", "realm", "other"))
+		raw := append([]byte{}, m.Raw...)
+		if k.Check(m) == nil {
+			t.Error("should be invalid")
+		}
+		if !bytes.Equal(m.Raw, raw) {
+			t.Error("message should not be changed")
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		if err := k.Check(MustBuild(NewSoftware("software"))); err != ErrAttributeNotFound {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("ZeroAlloc", func(t *testing.T) {
+		m := new(Message)
+		m.Raw = make([]byte, 0, 512)
+		testutil.ShouldNotAllocate(t, func() {
+			m.Reset()
+			m.WriteHeader()
+			if err := k.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			if err := k.Check(m); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+}
+
+func TestIntegrityKeys(t *testing.T) {
+	var (
+		oldKey = NewLongTermIntegrity("user", "realm", "old")
+		newKey = NewLongTermIntegrity("user", "realm", "new")
+		keys   = IntegrityKeys{newKey, oldKey}
+	)
+	for _, i := range []MessageIntegrity{oldKey, newKey} {
+		m := MustBuild(NewSoftware("software"), i, Fingerprint)
+		raw := append([]byte{}, m.Raw...)
+		got, err := keys.Check(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, i) {
+			t.Errorf("%s (got) != %s (expected)", got, i)
+		}
+		if !bytes.Equal(m.Raw, raw) {
+			t.Error("message should not be changed")
+		}
+	}
+	t.Run("Mismatch", func(t *testing.T) {
+		m := MustBuild(NewSoftware("software"), NewLongTermIntegrity("user", "realm", "other"))
+		if _, err := keys.Check(m); err != ErrIntegrityMismatch {
+			t.Errorf("unexpected error %v", err)
+		}
+		if _, err := (IntegrityKeys{}).Check(m); err != ErrIntegrityMismatch {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		if _, err := keys.Check(MustBuild(NewSoftware("software"))); err != ErrAttributeNotFound {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func BenchmarkIntegrityKeys_Check(b *testing.B) {
+	m := new(Message)
+	m.Raw = make([]byte, 0, 1024)
+	NewSoftware("software").AddTo(m)
+	keys := IntegrityKeys{NewShortTermIntegrity("old"), NewShortTermIntegrity("new")}
+	b.ReportAllocs()
+	m.WriteHeader()
+	if err := keys[1].AddTo(m); err != nil {
+		b.Error(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := keys.Check(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKeyedIntegrity_AddTo(b *testing.B) {
+	m := new(Message)
+	integrity := NewKeyedIntegrity(NewShortTermIntegrity("password"))
+	m.WriteHeader()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(m.Raw)))
+	for i := 0; i < b.N; i++ {
+		m.WriteHeader()
+		if err := integrity.AddTo(m); err != nil {
+			b.Error(err)
+		}
+		m.Reset()
+	}
+}
+
+func BenchmarkKeyedIntegrity_Check(b *testing.B) {
+	m := new(Message)
+	NewSoftware("software").AddTo(m)
+	integrity := NewKeyedIntegrity(NewShortTermIntegrity("password"))
+	b.ReportAllocs()
+	m.WriteHeader()
+	b.SetBytes(int64(len(m.Raw)))
+	if err := integrity.AddTo(m); err != nil {
+		b.Error(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if err := integrity.Check(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkMessageIntegrity_AddTo(b *testing.B) {
 	m := new(Message)
 	integrity := NewShortTermIntegrity("password")
@@ -122,3 +260,13 @@ func BenchmarkMessageIntegrity_Check(b *testing.B) {
 		}
 	}
 }
+
+func TestIsIntegrityMismatch(t *testing.T) {
+	m := MustBuild(NewSoftware("software"), NewShortTermIntegrity("pwd"))
+	if err := NewShortTermIntegrity("other").Check(m); !IsIntegrityMismatch(err) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if IsIntegrityMismatch(ErrAttributeNotFound) {
+		t.Error("should not be integrity mismatch")
+	}
+}
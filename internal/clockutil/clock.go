@@ -0,0 +1,61 @@
+// Package clockutil implements system clock and ticker-based collector that
+// are shared by stun and its subpackages.
+package clockutil
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts the source of current time, see stun.Clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is Clock that returns current system time.
+type System struct{}
+
+// Now returns time.Now().
+func (System) Now() time.Time { return time.Now() }
+
+// Collector calls function on each tick of time.Ticker with current time
+// of clock, see stun.Collector.
+type Collector struct {
+	close chan struct{}
+	wg    sync.WaitGroup
+	clock Clock
+}
+
+// NewCollector returns new Collector that uses clock.
+func NewCollector(clock Clock) *Collector {
+	return &Collector{
+		close: make(chan struct{}),
+		clock: clock,
+	}
+}
+
+// Start calls f with rate in separate goroutine until Close.
+func (a *Collector) Start(rate time.Duration, f func(now time.Time)) error {
+	t := time.NewTicker(rate)
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer t.Stop()
+		for {
+			select {
+			case <-a.close:
+				return
+			case <-t.C:
+				f(a.clock.Now())
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops collector, waiting for current call to return.
+func (a *Collector) Close() error {
+	close(a.close)
+	a.wg.Wait()
+	return nil
+}
@@ -34,6 +34,40 @@ func (h *hmac) resetTo(key []byte) {
 	h.marshaled = false
 }
 
+// resetToState resets h to precomputed marshaled states of inner and outer
+// hashes after ipad and opad are written.
+func (h *hmac) resetToState(ipad, opad []byte) {
+	h.ipad = append(h.ipad[:0], ipad...)
+	h.opad = append(h.opad[:0], opad...)
+	h.marshaled = true
+	h.Reset()
+}
+
+// SHA1Key is HMAC-SHA1 key with precomputed states of inner and outer
+// hashes, which saves key derivation and hashing of ipad and opad for
+// each message.
+type SHA1Key struct {
+	ipad, opad []byte
+}
+
+// NewSHA1Key returns new SHA1Key for key.
+func NewSHA1Key(key []byte) *SHA1Key {
+	h := New(sha1.New, key).(*hmac)
+	h.Reset() // marshaling states
+	if !h.marshaled {
+		panic("BUG: sha1 is not marshalable")
+	}
+	return &SHA1Key{ipad: h.ipad, opad: h.opad}
+}
+
+// AcquireSHA1Key returns new HMAC with precomputed key from pool.
+func AcquireSHA1Key(k *SHA1Key) hash.Hash {
+	h := hmacSHA1Pool.Get().(*hmac)
+	assertHMACSize(h, sha1.Size, sha1.BlockSize)
+	h.resetToState(k.ipad, k.opad)
+	return h
+}
+
 var hmacSHA1Pool = &sync.Pool{
 	New: func() interface{} {
 		h := New(sha1.New, make([]byte, sha1.BlockSize))
@@ -37,6 +37,21 @@ func BenchmarkHMACSHA1_512_Pool(b *testing.B) {
 	}
 }
 
+func BenchmarkHMACSHA1_512_Key(b *testing.B) {
+	key := NewSHA1Key(make([]byte, 32))
+	buf := make([]byte, 512)
+	tBuf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		h := AcquireSHA1Key(key)
+		h.Write(buf)
+		mac := h.Sum(tBuf)
+		buf[0] = mac[0]
+		PutSHA1(h)
+	}
+}
+
 func TestHMACReset(t *testing.T) {
 	for i, tt := range hmacTests {
 		h := New(tt.hash, tt.key)
@@ -102,6 +117,42 @@ func TestHMACPool_SHA1(t *testing.T) {
 	}
 }
 
+func TestHMACPool_SHA1Key(t *testing.T) {
+	for i, tt := range hmacTests {
+		if tt.blocksize != sha1.BlockSize || tt.size != sha1.Size {
+			continue
+		}
+		h := AcquireSHA1Key(NewSHA1Key(tt.key))
+		for j := 0; j < 2; j++ {
+			n, err := h.Write(tt.in)
+			if n != len(tt.in) || err != nil {
+				t.Errorf("test %d.%d: Write(%d) = %d, %v", i, j, len(tt.in), n, err)
+				continue
+			}
+
+			// Repetitive Sum() calls should return the same value
+			for k := 0; k < 2; k++ {
+				sum := fmt.Sprintf("%x", h.Sum(nil))
+				if sum != tt.out {
+					t.Errorf("test %d.%d.%d: have %s want %s\n", i, j, k, sum, tt.out)
+				}
+			}
+
+			// Second iteration: make sure reset works.
+			h.Reset()
+		}
+		// Same HMAC should be reusable with raw key.
+		h.(*hmac).resetTo(tt.key)
+		if _, err := h.Write(tt.in); err != nil {
+			t.Fatal(err)
+		}
+		if sum := fmt.Sprintf("%x", h.Sum(nil)); sum != tt.out {
+			t.Errorf("test %d: have %s want %s\n", i, sum, tt.out)
+		}
+		PutSHA1(h)
+	}
+}
+
 func TestHMACPool_SHA256(t *testing.T) {
 	for i, tt := range hmacTests {
 		if tt.blocksize != sha256.BlockSize || tt.size != sha256.Size {
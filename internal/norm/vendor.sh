@@ -0,0 +1,12 @@
+#!/bin/bash
+
+# Copy unicode/norm and transform packages from golang.org/x/text module,
+# which path is $XTEXT, without tests and with tables of single Unicode
+# version, rewriting imports.
+VERSION=15.0.0
+cp -v $XTEXT/unicode/norm/{composition,forminfo,input,iter,normalize,readwriter,transform,trie}.go .
+sed '/^\/\/go:build/d; /^\/\/ +build/,+1d' $XTEXT/unicode/norm/tables$VERSION.go > tables.go
+cp -v $XTEXT/transform/transform.go ../transform/
+sed -i 's|"golang.org/x/text/transform"|"gortc.io/stun/internal/transform"|' *.go
+sed -i 's|^\(package [a-z]*\) // import .*|\1|' *.go ../transform/transform.go
+git diff . ../transform
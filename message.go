@@ -111,12 +111,17 @@ func (m *Message) GobDecode(data []byte) error {
 	return m.UnmarshalBinary(data)
 }
 
-// AddTo sets b.TransactionID to m.TransactionID.
+// AddTo sets b.TransactionID to m.TransactionID. If m is classic
+// RFC 3489 message, the first 32 bits of 128-bit transaction id are also
+// written to b instead of magic cookie.
 //
 // Implements Setter to aid in crafting responses.
 func (m *Message) AddTo(b *Message) error {
 	b.TransactionID = m.TransactionID
 	b.WriteTransactionID()
+	if m.IsClassic() {
+		copy(b.Raw[4:8], m.Raw[4:8])
+	}
 	return nil
 }
 
@@ -354,6 +359,61 @@ var ErrUnexpectedHeaderEOF = errors.New("unexpected EOF: not enough bytes to rea
 
 // Decode decodes m.Raw into m.
 func (m *Message) Decode() error {
+	return m.decode(0)
+}
+
+// DecodeOption is bit flag that changes decoding behavior, flags can be
+// combined with bitwise OR.
+type DecodeOption byte
+
+const (
+	// StrictDecoding rejects messages that are accepted by default for
+	// compatibility but violate RFC 5389: top two bits of message type
+	// are set, message length is not a multiple of 4, padding of
+	// attribute is not zero, attribute is after FINGERPRINT or attribute
+	// is duplicated, except ones that can legitimately repeat, like
+	// XOR-PEER-ADDRESS.
+	//
+	// RFC 5389 Section 6 and Section 15
+	StrictDecoding DecodeOption = 1 << iota
+	// ClassicDecoding does not require magic cookie, like DecodeClassic.
+	ClassicDecoding
+)
+
+// DecodeWith decodes Message from data to m like Decode, but with
+// options.
+func DecodeWith(data []byte, m *Message, options DecodeOption) error {
+	if m == nil {
+		return ErrDecodeToNil
+	}
+	m.Raw = append(m.Raw[:0], data...)
+	return m.DecodeWith(options)
+}
+
+// DecodeWith decodes m.Raw into m like Decode, but with options.
+func (m *Message) DecodeWith(options DecodeOption) error {
+	return m.decode(options)
+}
+
+// repeatableAttrs are attributes that can appear more than once in
+// message, so they are not rejected by StrictDecoding.
+var repeatableAttrs = [...]AttrType{
+	AttrXORPeerAddress,    // RFC 5766 Section 9.1
+	AttrXORRelayedAddress, // RFC 8656 Section 18.5
+	AttrOrigin,            // draft-ietf-tram-stun-origin-06 Section 3
+}
+
+func isRepeatableAttr(t AttrType) bool {
+	for _, r := range repeatableAttrs {
+		if r == t {
+			return true
+		}
+	}
+	return false
+}
+
+// decode decodes m.Raw into m with options o.
+func (m *Message) decode(o DecodeOption) error {
 	// decoding message header
 	buf := m.Raw
 	if len(buf) < messageHeaderSize {
@@ -365,13 +425,22 @@ func (m *Message) Decode() error {
 		cookie   = bin.Uint32(buf[4:8])      // last 4 bytes
 		fullSize = messageHeaderSize + size  // len(m.Raw)
 	)
-	if cookie != magicCookie {
+	if o&ClassicDecoding == 0 && cookie != magicCookie {
 		msg := fmt.Sprintf("%x is invalid magic cookie (should be %x)", cookie, magicCookie)
-		return newDecodeErr("message", "cookie", msg)
+		return newDecodeErr("message", "cookie", msg).at(4, 4)
+	}
+	if o&StrictDecoding != 0 && t&0xC000 != 0 {
+		msg := fmt.Sprintf("%#04x has non-zero top two bits", t)
+		return newDecodeErr("message", "type", msg).at(0, 2)
+	}
+	if o&StrictDecoding != 0 && size%padding != 0 {
+		msg := fmt.Sprintf("%d is not a multiple of %d", size, padding)
+		return newDecodeErr("message", "length", msg).
+			at(2, 2).lengths(nearestPaddedValueLength(size), size)
 	}
 	if len(buf) < fullSize {
 		msg := fmt.Sprintf("buffer length %d is less than %d (expected message size)", len(buf), fullSize)
-		return newAttrDecodeErr("message", msg)
+		return newAttrDecodeErr("message", msg).at(2, 2).lengths(fullSize, len(buf))
 	}
 	// saving header data
 	m.Type.ReadValue(t)
@@ -384,10 +453,15 @@ func (m *Message) Decode() error {
 		b      = buf[messageHeaderSize:fullSize]
 	)
 	for offset < size {
+		var (
+			i     = len(m.Attributes)          // attribute index
+			start = messageHeaderSize + offset // attribute position in buf
+		)
 		// checking that we have enough bytes to read header
 		if len(b) < attributeHeaderSize {
 			msg := fmt.Sprintf("buffer length %d is less than %d (expected header size)", len(b), attributeHeaderSize)
-			return newAttrDecodeErr("header", msg)
+			return newAttrDecodeErr("header", msg).
+				at(start, len(b)).attr(i, 0).lengths(attributeHeaderSize, len(b))
 		}
 		var (
 			a = RawAttribute{
@@ -401,9 +475,15 @@ func (m *Message) Decode() error {
 		offset += attributeHeaderSize
 		if len(b) < aBuffL { // checking size
 			msg := fmt.Sprintf("buffer length %d is less than %d (expected value size for %s)", len(b), aBuffL, a.Type)
-			return newAttrDecodeErr("value", msg)
+			return newAttrDecodeErr("value", msg).
+				at(start, attributeHeaderSize+len(b)).attr(i, a.Type).lengths(aBuffL, len(b))
 		}
 		a.Value = b[:aL]
+		if o&StrictDecoding != 0 {
+			if err := m.checkStrict(a, start, b[aL:aBuffL]); err != nil {
+				return err
+			}
+		}
 		offset += aBuffL
 		b = b[aBuffL:]
 
@@ -412,6 +492,30 @@ func (m *Message) Decode() error {
 	return nil
 }
 
+// checkStrict returns error if attribute a at position start with
+// padding p can't be appended to decoded attributes in strict mode.
+func (m *Message) checkStrict(a RawAttribute, start int, p []byte) error {
+	var (
+		i    = len(m.Attributes)
+		size = attributeHeaderSize + len(a.Value) + len(p)
+	)
+	for _, c := range p {
+		if c != 0 {
+			msg := fmt.Sprintf("non-zero padding of %s", a.Type)
+			return newAttrDecodeErr("padding", msg).at(start+size-len(p), len(p)).attr(i, a.Type)
+		}
+	}
+	if i > 0 && m.Attributes[i-1].Type == AttrFingerprint {
+		msg := fmt.Sprintf("%s is after %s", a.Type, AttrFingerprint)
+		return newAttrDecodeErr("fingerprint", msg).at(start, size).attr(i, a.Type)
+	}
+	if !isRepeatableAttr(a.Type) && m.Contains(a.Type) {
+		msg := fmt.Sprintf("%s is duplicated", a.Type)
+		return newAttrDecodeErr("duplicate", msg).at(start, size).attr(i, a.Type)
+	}
+	return nil
+}
+
 // Write decodes message and return error if any.
 //
 // Any error is unrecoverable, but message could be partially decoded.
@@ -991,6 +991,127 @@ func TestDecode(t *testing.T) {
 	})
 }
 
+func TestDecodeWith(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		if err := DecodeWith(nil, nil, StrictDecoding); err != ErrDecodeToNil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	m := MustBuild(TransactionID, BindingRequest,
+		NewSoftware("soft"), NewUsername("user"),
+		&XORRelayedAddress{IP: net.IPv4(1, 2, 3, 4), Port: 1},
+		&XORRelayedAddress{IP: net.IPv4(1, 2, 3, 4), Port: 2},
+		Fingerprint,
+	)
+	decoded := new(Message)
+	if err := DecodeWith(m.Raw, decoded, StrictDecoding); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(m) {
+		t.Error("decoded result is not equal to encoded message")
+	}
+	t.Run("CreatePermission", func(t *testing.T) {
+		// Permissions for multiple peers. RFC 5766 Section 9.1
+		m := MustBuild(TransactionID, NewType(MethodCreatePermission, ClassRequest),
+			&XORPeerAddress{IP: net.IPv4(1, 2, 3, 4), Port: 1},
+			&XORPeerAddress{IP: net.IPv4(1, 2, 3, 5), Port: 2},
+			Fingerprint,
+		)
+		if err := DecodeWith(m.Raw, new(Message), StrictDecoding); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, tc := range []struct {
+		name    string
+		place   DecodeErrPlace
+		setup   func(m *Message)
+		invalid bool // also rejected by default
+	}{
+		{
+			name:  "Type",
+			place: DecodeErrPlace{"message", "type"},
+			setup: func(m *Message) {
+				m.Raw[0] |= 0x80
+			},
+		},
+		{
+			name:  "Length",
+			place: DecodeErrPlace{"message", "length"},
+			setup: func(m *Message) {
+				m.Raw = append(m.Raw, 0, 0)
+				m.Length += 2
+				m.WriteLength()
+			},
+			invalid: true,
+		},
+		{
+			name:  "Padding",
+			place: DecodeErrPlace{"attribute", "padding"},
+			setup: func(m *Message) {
+				m.Add(AttrRealm, []byte("realm"))
+				m.Raw[len(m.Raw)-1] = 1
+			},
+		},
+		{
+			name:  "Fingerprint",
+			place: DecodeErrPlace{"attribute", "fingerprint"},
+			setup: func(m *Message) {
+				m.Add(AttrRealm, []byte("real"))
+			},
+		},
+		{
+			name:  "Duplicate",
+			place: DecodeErrPlace{"attribute", "duplicate"},
+			setup: func(m *Message) {
+				m.Raw = m.Raw[:messageHeaderSize]
+				m.Length = 0
+				m.Attributes = m.Attributes[:0]
+				m.Add(AttrUsername, []byte("user"))
+				m.Add(AttrUsername, []byte("user"))
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := new(Message)
+			if err := m.CloneTo(c); err != nil {
+				t.Fatal(err)
+			}
+			tc.setup(c)
+			if err := DecodeWith(c.Raw, decoded, 0); (err != nil) != tc.invalid {
+				t.Errorf("unexpected lenient decoding error: %v", err)
+			}
+			err := DecodeWith(c.Raw, decoded, StrictDecoding)
+			if dErr, ok := err.(*DecodeErr); !ok || !dErr.IsPlace(tc.place) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	t.Run("Classic", func(t *testing.T) {
+		c := new(Message)
+		if err := m.CloneTo(c); err != nil {
+			t.Fatal(err)
+		}
+		c.Raw[4] = 0
+		if err := c.DecodeWith(StrictDecoding); err == nil {
+			t.Error("should error")
+		}
+		if err := c.DecodeWith(StrictDecoding | ClassicDecoding); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("ZeroAlloc", func(t *testing.T) {
+		allocs := testing.AllocsPerRun(10, func() {
+			decoded.Reset()
+			if err := DecodeWith(m.Raw, decoded, StrictDecoding); err != nil {
+				t.Error(err)
+			}
+		})
+		if allocs > 0 {
+			t.Error("unexpected allocations")
+		}
+	})
+}
+
 func BenchmarkDecode(b *testing.B) {
 	m := New()
 	m.Type = MessageType{Method: MethodBinding, Class: ClassRequest}
@@ -0,0 +1,398 @@
+package stun
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// NATBehavior is mapping or filtering behavior of NAT as defined
+// in RFC 4787.
+type NATBehavior byte
+
+// Possible NAT behaviors.
+const (
+	BehaviorUnknown                 NATBehavior = iota
+	BehaviorEndpointIndependent                 // endpoint-independent
+	BehaviorAddressDependent                    // address-dependent
+	BehaviorAddressAndPortDependent             // address and port-dependent
+)
+
+func (b NATBehavior) String() string {
+	switch b {
+	case BehaviorEndpointIndependent:
+		return "endpoint-independent"
+	case BehaviorAddressDependent:
+		return "address-dependent"
+	case BehaviorAddressAndPortDependent:
+		return "address and port-dependent"
+	default:
+		return "unknown"
+	}
+}
+
+// NATDiscovery is result of NAT behavior discovery.
+//
+// RFC 5780 Section 4
+type NATDiscovery struct {
+	// MappedAddress is XOR-MAPPED-ADDRESS from the first binding response.
+	MappedAddress XORMappedAddress
+	// OtherAddress is OTHER-ADDRESS reported by server.
+	OtherAddress OtherAddress
+	// NAT is false if MappedAddress is the local address.
+	NAT       bool
+	Mapping   NATBehavior
+	Filtering NATBehavior
+}
+
+// ErrNoOtherAddress means that server response has no OTHER-ADDRESS
+// attribute, so server does not support NAT behavior discovery.
+var ErrNoOtherAddress = errors.New("no OTHER-ADDRESS in response")
+
+// ErrUnexpectedResponseSource means that response was received from
+// address that does not match the CHANGE-REQUEST.
+var ErrUnexpectedResponseSource = errors.New("response from unexpected address")
+
+// ErrUnexpectedResponseType means that response has unexpected message type.
+var ErrUnexpectedResponseType = errors.New("unexpected response type")
+
+// ResponseErr wraps ERROR-CODE attribute from error response.
+type ResponseErr struct {
+	Code ErrorCodeAttribute
+}
+
+func (e ResponseErr) Error() string {
+	return "error response: " + e.Code.String()
+}
+
+// packetConnection adapts net.PacketConn to Connection, writing to
+// configurable remote address and recording source of last read.
+//
+// Close does not close underlying connection but unblocks pending read.
+type packetConnection struct {
+	conn   net.PacketConn
+	mux    sync.Mutex
+	remote net.Addr
+	from   net.Addr // accessed only by reading goroutine
+}
+
+func (c *packetConnection) setRemote(addr net.Addr) {
+	c.mux.Lock()
+	c.remote = addr
+	c.mux.Unlock()
+}
+
+func (c *packetConnection) Read(b []byte) (int, error) {
+	n, addr, err := c.conn.ReadFrom(b)
+	c.from = addr
+	return n, err
+}
+
+func (c *packetConnection) Write(b []byte) (int, error) {
+	c.mux.Lock()
+	remote := c.remote
+	c.mux.Unlock()
+	return c.conn.WriteTo(b, remote)
+}
+
+func (c *packetConnection) Close() error {
+	// Forcing pending ReadFrom to return.
+	return c.conn.SetReadDeadline(time.Unix(1, 0))
+}
+
+// natDiscoverer runs RFC 5780 tests sequentially on single Client.
+type natDiscoverer struct {
+	conn   *packetConnection
+	client *Client
+}
+
+// natResponse is result of single discovery test.
+type natResponse struct {
+	mapped XORMappedAddress
+	other  OtherAddress
+	from   net.Addr
+}
+
+func (d *natDiscoverer) do(to net.Addr, setters ...Setter) (*natResponse, error) {
+	d.conn.setRemote(to)
+	m, err := Build(append([]Setter{TransactionID, BindingRequest}, setters...)...)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		res      *natResponse
+		eventErr error
+	)
+	if err = d.client.Do(m, func(e Event) {
+		if e.Error != nil {
+			eventErr = e.Error
+			return
+		}
+		if e.Message.Type != BindingSuccess {
+			var code ErrorCodeAttribute
+			if code.GetFrom(e.Message) == nil {
+				eventErr = ResponseErr{Code: code}
+				return
+			}
+			eventErr = ErrUnexpectedResponseType
+			return
+		}
+		r := &natResponse{from: d.conn.from}
+		if eventErr = r.mapped.GetFrom(e.Message); eventErr != nil {
+			return
+		}
+		if getErr := r.other.GetFrom(e.Message); getErr != nil && getErr != ErrAttributeNotFound {
+			eventErr = getErr
+			return
+		}
+		res = r
+	}); err != nil {
+		return nil, err
+	}
+	return res, eventErr
+}
+
+func addrEqual(a, b net.IP, aPort, bPort int) bool {
+	return aPort == bPort && a.Equal(b)
+}
+
+func udpAddrEqual(a net.Addr, ip net.IP, port int) bool {
+	u, ok := a.(*net.UDPAddr)
+	if !ok {
+		return false
+	}
+	return addrEqual(u.IP, ip, u.Port, port)
+}
+
+// isLocalAddr reports whether ip:port is the local address of conn.
+func isLocalAddr(conn net.PacketConn, ip net.IP, port int) bool {
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || local.Port != port {
+		return false
+	}
+	if !local.IP.IsUnspecified() {
+		return local.IP.Equal(ip)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverNAT determines NAT mapping and filtering behavior for conn
+// using RFC 5780 capable STUN server. All tests are done sequentially
+// from conn, so it must not be read by anything else until DiscoverNAT
+// returns.
+//
+// Options are passed to the underlying Client, the WithRTO option
+// can be used to reduce the time needed to detect the filtering,
+// which relies on transaction time outs. Do not use WithNoConnClose.
+//
+// Returns ErrNoOtherAddress if server does not support RFC 5780.
+//
+// RFC 5780 Section 4.3 and 4.4
+func DiscoverNAT(conn net.PacketConn, server net.Addr, options ...ClientOption) (*NATDiscovery, error) {
+	pConn := &packetConnection{conn: conn, remote: server}
+	c, err := NewClient(pConn, options...)
+	if err != nil {
+		return nil, err
+	}
+	d := &natDiscoverer{conn: pConn, client: c}
+	res, err := d.discover(server)
+	if err = closePacketClient(c, conn, err); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// closePacketClient closes Client that was created on packetConnection
+// for conn, resetting conn read deadline. Returns err if not nil or first
+// error that occurred.
+func closePacketClient(c *Client, conn net.PacketConn, err error) error {
+	closeErr := c.Close()
+	if deadlineErr := conn.SetReadDeadline(time.Time{}); err == nil {
+		err = deadlineErr
+	}
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (d *natDiscoverer) discover(server net.Addr) (*NATDiscovery, error) {
+	primary, ok := server.(*net.UDPAddr)
+	if !ok {
+		return nil, net.UnknownNetworkError(server.Network())
+	}
+	// Test I.
+	first, err := d.do(primary)
+	if err != nil {
+		return nil, err
+	}
+	if first.other.IP == nil {
+		return nil, ErrNoOtherAddress
+	}
+	res := &NATDiscovery{
+		MappedAddress: first.mapped,
+		OtherAddress:  first.other,
+		NAT:           !isLocalAddr(d.conn.conn, first.mapped.IP, first.mapped.Port),
+	}
+	var (
+		otherIP   = first.other.IP
+		otherPort = first.other.Port
+	)
+	// Filtering tests are done first, because mapping tests are creating
+	// bindings to the alternate address, which will render filtering
+	// tests useless.
+	//
+	// Test II: requesting response from alternate address and port.
+	filtering, err := d.do(primary, ChangeRequest{ChangeIP: true, ChangePort: true})
+	switch err {
+	case nil:
+		if !udpAddrEqual(filtering.from, otherIP, otherPort) {
+			return nil, ErrUnexpectedResponseSource
+		}
+		res.Filtering = BehaviorEndpointIndependent
+	case ErrTransactionTimeOut:
+		// Test III: requesting response from alternate port.
+		filtering, err = d.do(primary, ChangeRequest{ChangePort: true})
+		switch err {
+		case nil:
+			if !udpAddrEqual(filtering.from, primary.IP, otherPort) {
+				return nil, ErrUnexpectedResponseSource
+			}
+			res.Filtering = BehaviorAddressDependent
+		case ErrTransactionTimeOut:
+			res.Filtering = BehaviorAddressAndPortDependent
+		default:
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	// Test II: sending to alternate address and primary port.
+	second, err := d.do(&net.UDPAddr{IP: otherIP, Port: primary.Port})
+	if err != nil {
+		return nil, err
+	}
+	if addrEqual(first.mapped.IP, second.mapped.IP, first.mapped.Port, second.mapped.Port) {
+		res.Mapping = BehaviorEndpointIndependent
+		return res, nil
+	}
+	// Test III: sending to alternate address and alternate port.
+	third, err := d.do(&net.UDPAddr{IP: otherIP, Port: otherPort})
+	if err != nil {
+		return nil, err
+	}
+	if addrEqual(second.mapped.IP, third.mapped.IP, second.mapped.Port, third.mapped.Port) {
+		res.Mapping = BehaviorAddressDependent
+	} else {
+		res.Mapping = BehaviorAddressAndPortDependent
+	}
+	return res, nil
+}
+
+// natProber creates bindings from x via Client and sends requests from y,
+// waiting for them (or responses to them) to be received on x.
+type natProber struct {
+	x        net.PacketConn
+	y        net.PacketConn
+	client   *Client
+	server   net.Addr
+	timeout  time.Duration
+	received chan transactionID
+}
+
+const natProbeAttempts = 3
+
+func newNATProber(x, y net.PacketConn, server net.Addr, timeout time.Duration, options ...ClientOption) (*natProber, error) {
+	p := &natProber{
+		x:        x,
+		y:        y,
+		server:   server,
+		timeout:  timeout,
+		received: make(chan transactionID, natProbeAttempts*2),
+	}
+	pConn := &packetConnection{conn: x, remote: server}
+	client, err := NewClient(pConn, append([]ClientOption{WithHandler(p.handle)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	p.client = client
+	return p, nil
+}
+
+func (p *natProber) close(err error) error {
+	return closePacketClient(p.client, p.x, err)
+}
+
+// handle is called for messages received on x that does not belong
+// to client transactions.
+func (p *natProber) handle(e Event) {
+	if e.Message == nil {
+		return
+	}
+	select {
+	case p.received <- e.TransactionID:
+	default:
+	}
+}
+
+// bind creates binding from x, returning mapped address.
+func (p *natProber) bind() (XORMappedAddress, error) {
+	var (
+		addr     XORMappedAddress
+		eventErr error
+	)
+	m, err := Build(TransactionID, BindingRequest)
+	if err != nil {
+		return addr, err
+	}
+	if err = p.client.Do(m, func(e Event) {
+		if e.Error != nil {
+			eventErr = e.Error
+			return
+		}
+		eventErr = addr.GetFrom(e.Message)
+	}); err != nil {
+		return addr, err
+	}
+	return addr, eventErr
+}
+
+// sendAndWait sends m from y to addr, retransmitting it, and reports
+// whether message with the same transaction id was received on x
+// during timeout.
+func (p *natProber) sendAndWait(m *Message, addr net.Addr) (bool, error) {
+	var (
+		deadline = time.NewTimer(p.timeout)
+		ticker   = time.NewTicker(p.timeout / natProbeAttempts)
+		attempt  = 0
+	)
+	defer deadline.Stop()
+	defer ticker.Stop()
+	for {
+		if attempt < natProbeAttempts {
+			if _, err := p.y.WriteTo(m.Raw, addr); err != nil {
+				return false, err
+			}
+			attempt++
+		}
+		select {
+		case id := <-p.received:
+			if id == m.TransactionID {
+				return true, nil
+			}
+		case <-ticker.C:
+		case <-deadline.C:
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package stun
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// natTestServer is RFC 5780 responder bound to two addresses and
+// two ports, with hooks for NAT simulation.
+type natTestServer struct {
+	t     testing.TB
+	conns [2][2]net.PacketConn // [ip][port]
+	wg    sync.WaitGroup
+
+	// mapped returns mapped address for request from addr
+	// received on conns[ip][port].
+	mapped func(addr *net.UDPAddr, ip, port int) *net.UDPAddr
+	// drop reports whether response should be dropped.
+	drop func(c ChangeRequest) bool
+	// expired reports whether binding for RESPONSE-PORT destination
+	// is expired, so response should be dropped.
+	expired func(to *net.UDPAddr) bool
+}
+
+// skipWithoutLoopbackAlias skips test if 127.0.0.2 can't be bound, e.g.
+// on macOS, where loopback has no aliases by default.
+func skipWithoutLoopbackAlias(t testing.TB) {
+	t.Helper()
+	c, err := net.ListenPacket("udp4", "127.0.0.2:0")
+	if err != nil {
+		t.Skip("127.0.0.2 is not available:", err)
+	}
+	_ = c.Close()
+}
+
+// start binds s to loopback addresses and starts serving.
+func (s *natTestServer) start(t testing.TB) {
+	t.Helper()
+	skipWithoutLoopbackAlias(t)
+	s.t = t
+	for attempt := 0; attempt < 10; attempt++ {
+		if s.listen() {
+			for ip := range s.conns {
+				for port := range s.conns[ip] {
+					s.wg.Add(1)
+					go s.serve(ip, port)
+				}
+			}
+			return
+		}
+	}
+	t.Fatal("failed to listen")
+}
+
+func (s *natTestServer) listen() bool {
+	var ports [2]int
+	for port := range ports {
+		c, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		s.conns[0][port] = c
+		ports[port] = c.LocalAddr().(*net.UDPAddr).Port
+	}
+	for port := range ports {
+		c, err := net.ListenPacket("udp4", (&net.UDPAddr{
+			IP: net.IPv4(127, 0, 0, 2), Port: ports[port],
+		}).String())
+		if err != nil {
+			s.close()
+			return false
+		}
+		s.conns[1][port] = c
+	}
+	return true
+}
+
+func (s *natTestServer) close() {
+	for ip := range s.conns {
+		for port := range s.conns[ip] {
+			if s.conns[ip][port] != nil {
+				s.conns[ip][port].Close()
+			}
+		}
+	}
+}
+
+func (s *natTestServer) Close() {
+	s.close()
+	s.wg.Wait()
+}
+
+func (s *natTestServer) addr(ip, port int) *net.UDPAddr {
+	return s.conns[ip][port].LocalAddr().(*net.UDPAddr)
+}
+
+func (s *natTestServer) serve(ip, port int) {
+	defer s.wg.Done()
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := s.conns[ip][port].ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := new(Message)
+		if _, err = req.Write(buf[:n]); err != nil {
+			s.t.Error(err)
+			continue
+		}
+		var (
+			change   ChangeRequest
+			port2    ResponsePort
+			respIP   = ip
+			respPort = port
+			mapped   = addr.(*net.UDPAddr)
+			to       = mapped
+		)
+		if err = change.GetFrom(req); err != nil && err != ErrAttributeNotFound {
+			s.t.Error(err)
+			continue
+		}
+		if port2.GetFrom(req) == nil {
+			to = &net.UDPAddr{IP: mapped.IP, Port: int(port2)}
+			if s.expired != nil && s.expired(to) {
+				continue
+			}
+		}
+		if change.ChangeIP {
+			respIP = 1 - ip
+		}
+		if change.ChangePort {
+			respPort = 1 - port
+		}
+		if s.drop != nil && s.drop(change) {
+			continue
+		}
+		if s.mapped != nil {
+			mapped = s.mapped(mapped, ip, port)
+		}
+		other := s.addr(1-ip, 1-port)
+		origin := s.addr(respIP, respPort)
+		res := MustBuild(req, BindingSuccess,
+			&XORMappedAddress{IP: mapped.IP, Port: mapped.Port},
+			&OtherAddress{IP: other.IP, Port: other.Port},
+			&ResponseOrigin{IP: origin.IP, Port: origin.Port},
+			Fingerprint,
+		)
+		if _, err = s.conns[respIP][respPort].WriteTo(res.Raw, to); err != nil {
+			s.t.Error(err)
+		}
+	}
+}
+
+func TestDiscoverNAT(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		mapped    func(addr *net.UDPAddr, ip, port int) *net.UDPAddr
+		drop      func(c ChangeRequest) bool
+		nat       bool
+		mapping   NATBehavior
+		filtering NATBehavior
+	}{
+		{
+			name:      "NoNAT",
+			mapping:   BehaviorEndpointIndependent,
+			filtering: BehaviorEndpointIndependent,
+		},
+		{
+			name: "AddressDependent",
+			mapped: func(addr *net.UDPAddr, ip, port int) *net.UDPAddr {
+				return &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1000 + ip}
+			},
+			drop: func(c ChangeRequest) bool {
+				return c.ChangeIP
+			},
+			nat:       true,
+			mapping:   BehaviorAddressDependent,
+			filtering: BehaviorAddressDependent,
+		},
+		{
+			name: "AddressAndPortDependent",
+			mapped: func(addr *net.UDPAddr, ip, port int) *net.UDPAddr {
+				return &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1000 + ip*2 + port}
+			},
+			drop: func(c ChangeRequest) bool {
+				return c.ChangeIP || c.ChangePort
+			},
+			nat:       true,
+			mapping:   BehaviorAddressAndPortDependent,
+			filtering: BehaviorAddressAndPortDependent,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &natTestServer{mapped: tc.mapped, drop: tc.drop}
+			s.start(t)
+			defer s.Close()
+			conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			res, err := DiscoverNAT(conn, s.addr(0, 0), WithRTO(time.Millisecond*5))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.NAT != tc.nat {
+				t.Errorf("NAT: %v (got) != %v (expected)", res.NAT, tc.nat)
+			}
+			if res.Mapping != tc.mapping {
+				t.Errorf("mapping: %s (got) != %s (expected)", res.Mapping, tc.mapping)
+			}
+			if res.Filtering != tc.filtering {
+				t.Errorf("filtering: %s (got) != %s (expected)", res.Filtering, tc.filtering)
+			}
+			other := s.addr(1, 1)
+			if !res.OtherAddress.IP.Equal(other.IP) || res.OtherAddress.Port != other.Port {
+				t.Errorf("bad other address %s", res.OtherAddress)
+			}
+			// Connection should be usable after discovery.
+			if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := conn.WriteTo(MustBuild(TransactionID, BindingRequest).Raw, s.addr(0, 0)); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 1024)
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsMessage(buf[:n]) {
+				t.Error("should be message")
+			}
+		})
+	}
+	t.Run("NoOtherAddress", func(t *testing.T) {
+		server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		go func() {
+			buf := make([]byte, 1024)
+			n, addr, readErr := server.ReadFrom(buf)
+			if readErr != nil {
+				return
+			}
+			req := new(Message)
+			if _, readErr = req.Write(buf[:n]); readErr != nil {
+				t.Error(readErr)
+			}
+			udpAddr := addr.(*net.UDPAddr)
+			res := MustBuild(req, BindingSuccess, &XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port})
+			if _, writeErr := server.WriteTo(res.Raw, addr); writeErr != nil {
+				t.Error(writeErr)
+			}
+		}()
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err = DiscoverNAT(conn, server.LocalAddr(), WithRTO(time.Millisecond*5)); err != ErrNoOtherAddress {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("NoResponse", func(t *testing.T) {
+		server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err = DiscoverNAT(conn, server.LocalAddr(), WithRTO(time.Millisecond)); err != ErrTransactionTimeOut {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestNATBehavior_String(t *testing.T) {
+	for b, s := range map[NATBehavior]string{
+		BehaviorUnknown:                 "unknown",
+		BehaviorEndpointIndependent:     "endpoint-independent",
+		BehaviorAddressDependent:        "address-dependent",
+		BehaviorAddressAndPortDependent: "address and port-dependent",
+	} {
+		if b.String() != s {
+			t.Errorf("%q (got) != %q (expected)", b, s)
+		}
+	}
+}
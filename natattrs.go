@@ -0,0 +1,132 @@
+package stun
+
+import "errors"
+
+// ChangeRequest represents CHANGE-REQUEST attribute.
+//
+// The CHANGE-REQUEST attribute contains two flags to control the IP
+// address and port that the server uses to send the response.
+//
+// RFC 5780 Section 7.2
+type ChangeRequest struct {
+	ChangeIP   bool // flag "A"
+	ChangePort bool // flag "B"
+}
+
+// constants for CHANGE-REQUEST encoding.
+const (
+	changeRequestSize    = 4
+	changeRequestIPBit   = 0x04
+	changeRequestPortBit = 0x02
+)
+
+func (c ChangeRequest) String() string {
+	switch {
+	case c.ChangeIP && c.ChangePort:
+		return "change IP and port"
+	case c.ChangeIP:
+		return "change IP"
+	case c.ChangePort:
+		return "change port"
+	default:
+		return "no change"
+	}
+}
+
+// AddTo adds CHANGE-REQUEST attribute to message.
+func (c ChangeRequest) AddTo(m *Message) error {
+	v := make([]byte, changeRequestSize)
+	if c.ChangeIP {
+		v[3] |= changeRequestIPBit
+	}
+	if c.ChangePort {
+		v[3] |= changeRequestPortBit
+	}
+	m.Add(AttrChangeRequest, v)
+	return nil
+}
+
+// GetFrom decodes CHANGE-REQUEST from message.
+func (c *ChangeRequest) GetFrom(m *Message) error {
+	v, err := m.Get(AttrChangeRequest)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrChangeRequest, len(v), changeRequestSize); err != nil {
+		return err
+	}
+	c.ChangeIP = v[3]&changeRequestIPBit != 0
+	c.ChangePort = v[3]&changeRequestPortBit != 0
+	return nil
+}
+
+// ResponsePort represents RESPONSE-PORT attribute.
+//
+// The RESPONSE-PORT attribute contains a port to which the server
+// sends the response instead of the source port of the request.
+//
+// RFC 5780 Section 7.5
+type ResponsePort uint16
+
+const responsePortSize = 4 // 16 bit port and 16 bit padding
+
+// AddTo adds RESPONSE-PORT attribute to message.
+func (p ResponsePort) AddTo(m *Message) error {
+	v := make([]byte, responsePortSize)
+	bin.PutUint16(v[0:2], uint16(p))
+	m.Add(AttrResponsePort, v)
+	return nil
+}
+
+// GetFrom decodes RESPONSE-PORT from message.
+func (p *ResponsePort) GetFrom(m *Message) error {
+	v, err := m.Get(AttrResponsePort)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrResponsePort, len(v), responsePortSize); err != nil {
+		return err
+	}
+	*p = ResponsePort(bin.Uint16(v[0:2]))
+	return nil
+}
+
+// Padding represents PADDING attribute.
+//
+// The PADDING attribute allows for the entire message to be padded to
+// force the STUN message to be divided into IP fragments. Its value
+// is ignored and its length must be a multiple of 4.
+//
+// RFC 5780 Section 7.6
+type Padding []byte
+
+// NewPadding returns Padding of n zero bytes.
+func NewPadding(n int) Padding {
+	return make(Padding, n)
+}
+
+// ErrBadPaddingSize means that PADDING attribute value length is not
+// a multiple of 4.
+var ErrBadPaddingSize = errors.New("bad PADDING size")
+
+// AddTo adds PADDING attribute to message.
+func (p Padding) AddTo(m *Message) error {
+	if len(p)%padding != 0 {
+		return ErrBadPaddingSize
+	}
+	m.Add(AttrPadding, p)
+	return nil
+}
+
+// GetFrom gets PADDING from message. Value is valid until m.Raw is valid.
+func (p *Padding) GetFrom(m *Message) error {
+	v, err := m.Get(AttrPadding)
+	if err != nil {
+		return err
+	}
+	if len(v)%padding != 0 {
+		return ErrBadPaddingSize
+	}
+	*p = v
+	return nil
+}
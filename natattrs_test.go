@@ -0,0 +1,123 @@
+package stun
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChangeRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   ChangeRequest
+		v    byte
+	}{
+		{"None", ChangeRequest{}, 0x00},
+		{"IP", ChangeRequest{ChangeIP: true}, 0x04},
+		{"Port", ChangeRequest{ChangePort: true}, 0x02},
+		{"Both", ChangeRequest{ChangeIP: true, ChangePort: true}, 0x06},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(Message)
+			if err := tc.in.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			v, err := m.Get(AttrChangeRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(v, []byte{0, 0, 0, tc.v}) {
+				t.Errorf("unexpected value %x", v)
+			}
+			var got ChangeRequest
+			if err := got.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.in {
+				t.Errorf("%s (got) != %s (expected)", got, tc.in)
+			}
+		})
+	}
+	t.Run("Not found", func(t *testing.T) {
+		var c ChangeRequest
+		if err := c.GetFrom(new(Message)); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrChangeRequest, []byte{1, 2, 3})
+		var c ChangeRequest
+		if err := c.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestResponsePort(t *testing.T) {
+	m := new(Message)
+	p := ResponsePort(42123)
+	if err := p.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got ResponsePort
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != p {
+		t.Errorf("%d (got) != %d (expected)", got, p)
+	}
+	t.Run("Not found", func(t *testing.T) {
+		if err := got.GetFrom(new(Message)); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		message := new(Message)
+		message.Add(AttrResponsePort, []byte{1, 2, 3})
+		if err := got.GetFrom(message); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestPadding_AddTo(t *testing.T) {
+	m := new(Message)
+	p := NewPadding(16)
+	if err := p.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got Padding
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, p) {
+		t.Error("value mismatch")
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		if err := NewPadding(3).AddTo(new(Message)); err != ErrBadPaddingSize {
+			t.Error("should be bad size: ", err)
+		}
+		message := new(Message)
+		message.Add(AttrPadding, []byte{1, 2, 3})
+		if err := got.GetFrom(message); err != ErrBadPaddingSize {
+			t.Error("should be bad size: ", err)
+		}
+	})
+	t.Run("Not found", func(t *testing.T) {
+		if err := got.GetFrom(new(Message)); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+}
+
+func BenchmarkChangeRequest_AddTo(b *testing.B) {
+	m := new(Message)
+	b.ReportAllocs()
+	c := ChangeRequest{ChangeIP: true, ChangePort: true}
+	for i := 0; i < b.N; i++ {
+		if err := c.AddTo(m); err != nil {
+			b.Fatal(err)
+		}
+		m.Reset()
+	}
+}
@@ -0,0 +1,94 @@
+package stun
+
+import (
+	"net"
+	"time"
+)
+
+// LifetimeConfig configures NAT binding lifetime measurement.
+type LifetimeConfig struct {
+	Min       time.Duration // lower bound of lifetime, defaults to zero
+	Max       time.Duration // upper bound of lifetime, defaults to 5 minutes
+	Precision time.Duration // search stops when bounds are closer, defaults to 5 seconds
+	Timeout   time.Duration // time to wait for probe response, defaults to 1 second
+	Options   []ClientOption
+}
+
+const (
+	defaultLifetimeMax       = time.Minute * 5
+	defaultLifetimePrecision = time.Second * 5
+	defaultLifetimeTimeout   = time.Second
+)
+
+// BindingLifetime is result of NAT binding lifetime measurement: the binding
+// was alive after being idle for Alive and expired after being idle for
+// Expired.
+type BindingLifetime struct {
+	Alive   time.Duration
+	Expired time.Duration // zero if binding was never expired
+}
+
+// probeLifetime reports whether the binding from x is alive after idle period.
+func (p *natProber) probeLifetime(idle time.Duration) (bool, error) {
+	mapped, err := p.bind()
+	if err != nil {
+		return false, err
+	}
+	time.Sleep(idle)
+	m, err := Build(TransactionID, BindingRequest, ResponsePort(mapped.Port))
+	if err != nil {
+		return false, err
+	}
+	return p.sendAndWait(m, p.server)
+}
+
+// MeasureBindingLifetime estimates how long NAT keeps idle UDP binding
+// alive using binary search between c.Min and c.Max. Each probe creates
+// binding from x, waits and then sends request from y with RESPONSE-PORT
+// set to mapped port of x, checking that response is received on x.
+//
+// The server must support RESPONSE-PORT attribute. Both x and y must be
+// behind the same NAT and must not be read by anything else until
+// MeasureBindingLifetime returns. Measurement takes time comparable to
+// c.Max multiplied by number of probes.
+//
+// RFC 5780 Section 4.6
+func MeasureBindingLifetime(x, y net.PacketConn, server net.Addr, c LifetimeConfig) (*BindingLifetime, error) {
+	if c.Max == 0 {
+		c.Max = defaultLifetimeMax
+	}
+	if c.Precision == 0 {
+		c.Precision = defaultLifetimePrecision
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultLifetimeTimeout
+	}
+	p, err := newNATProber(x, y, server, c.Timeout, c.Options...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.searchLifetime(c.Min, c.Max, c.Precision)
+	if err = p.close(err); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *natProber) searchLifetime(lo, hi, precision time.Duration) (*BindingLifetime, error) {
+	res := new(BindingLifetime)
+	for hi-lo > precision {
+		mid := lo + (hi-lo)/2
+		alive, err := p.probeLifetime(mid)
+		if err != nil {
+			return nil, err
+		}
+		if alive {
+			lo = mid
+		} else {
+			hi = mid
+			res.Expired = mid
+		}
+	}
+	res.Alive = lo
+	return res, nil
+}
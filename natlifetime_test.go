@@ -0,0 +1,80 @@
+package stun
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMeasureBindingLifetime(t *testing.T) {
+	const lifetime = time.Millisecond * 700
+	var (
+		mux      sync.Mutex
+		lastSeen = make(map[int]time.Time)
+	)
+	s := &natTestServer{
+		mapped: func(addr *net.UDPAddr, ip, port int) *net.UDPAddr {
+			mux.Lock()
+			lastSeen[addr.Port] = time.Now()
+			mux.Unlock()
+			return addr
+		},
+		expired: func(to *net.UDPAddr) bool {
+			mux.Lock()
+			defer mux.Unlock()
+			return time.Since(lastSeen[to.Port]) > lifetime
+		},
+	}
+	s.start(t)
+	defer s.Close()
+	x, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	y, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	// Probing after 800ms (expired) and 400ms (alive), leaving wide
+	// margins for slow test environments.
+	res, err := MeasureBindingLifetime(x, y, s.addr(0, 0), LifetimeConfig{
+		Max:       time.Millisecond * 1600,
+		Precision: time.Millisecond * 400,
+		Timeout:   time.Millisecond * 600,
+		Options:   []ClientOption{WithRTO(time.Millisecond * 10)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Alive != time.Millisecond*400 || res.Expired != time.Millisecond*800 {
+		t.Errorf("bad lifetime estimate: alive %s, expired %s", res.Alive, res.Expired)
+	}
+	t.Run("NoExpiration", func(t *testing.T) {
+		res, err := MeasureBindingLifetime(x, y, s.addr(0, 0), LifetimeConfig{
+			Max:       time.Millisecond * 40,
+			Precision: time.Millisecond * 20,
+			Timeout:   time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Expired != 0 || res.Alive != time.Millisecond*20 {
+			t.Errorf("bad lifetime estimate: alive %s, expired %s", res.Alive, res.Expired)
+		}
+	})
+	t.Run("NoResponse", func(t *testing.T) {
+		server, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		if _, err = MeasureBindingLifetime(x, y, server.LocalAddr(), LifetimeConfig{
+			Options: []ClientOption{WithRTO(time.Millisecond)},
+		}); err != ErrTransactionTimeOut {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package stun
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"time"
+)
+
+// ThirdPartyAuthorization represents THIRD-PARTY-AUTHORIZATION attribute,
+// which is sent by server in 401 response to signal that it supports
+// third-party authorization, the value is STUN server name.
+//
+// RFC 7635 Section 6.1
+type ThirdPartyAuthorization []byte
+
+// NewThirdPartyAuthorization returns new ThirdPartyAuthorization from
+// server name.
+func NewThirdPartyAuthorization(server string) ThirdPartyAuthorization {
+	return ThirdPartyAuthorization(server)
+}
+
+func (a ThirdPartyAuthorization) String() string {
+	return string(a)
+}
+
+const maxThirdPartyAuthorizationB = 763
+
+// AddTo adds THIRD-PARTY-AUTHORIZATION to message.
+func (a ThirdPartyAuthorization) AddTo(m *Message) error {
+	return TextAttribute(a).AddToAs(m, AttrThirdPartyAuthorization, maxThirdPartyAuthorizationB)
+}
+
+// GetFrom gets THIRD-PARTY-AUTHORIZATION from message.
+func (a *ThirdPartyAuthorization) GetFrom(m *Message) error {
+	return (*TextAttribute)(a).GetFromAs(m, AttrThirdPartyAuthorization)
+}
+
+// AccessToken represents ACCESS-TOKEN attribute, which is self-contained
+// token encrypted by authorization server, see TokenCodec.
+//
+// RFC 7635 Section 6.2
+type AccessToken []byte
+
+// AddTo adds ACCESS-TOKEN to message.
+func (t AccessToken) AddTo(m *Message) error {
+	m.Add(AttrAccessToken, t)
+	return nil
+}
+
+// GetFrom gets ACCESS-TOKEN from message. Value is valid until m.Raw is
+// valid.
+func (t *AccessToken) GetFrom(m *Message) error {
+	v, err := m.Get(AttrAccessToken)
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// Token is decrypted content of access token.
+//
+// RFC 7635 Section 6.2
+type Token struct {
+	MACKey    []byte        // key of MESSAGE-INTEGRITY
+	Timestamp time.Time     // of token creation
+	Lifetime  time.Duration // since Timestamp, truncated to seconds
+}
+
+// Integrity returns MessageIntegrity that is keyed by mac_key of token.
+//
+// RFC 7635 Section 4.1
+func (t Token) Integrity() MessageIntegrity {
+	return MessageIntegrity(append([]byte{}, t.MACKey...))
+}
+
+// Expired reports whether token is expired at now.
+func (t Token) Expired(now time.Time) bool {
+	return !now.Before(t.Timestamp.Add(t.Lifetime))
+}
+
+// ErrInvalidToken means that access token can't be decrypted or is
+// malformed.
+var ErrInvalidToken = errors.New("invalid access token")
+
+// Token timestamp is 48 bits of seconds since unix epoch followed by 16
+// bits of second fraction in 1/64000 units.
+const (
+	tokenTimestampFraction = 64000
+	tokenTimestampShift    = 16
+)
+
+func encodeTokenTimestamp(t time.Time) uint64 {
+	fraction := uint64(t.Nanosecond()) * tokenTimestampFraction / uint64(time.Second)
+	return uint64(t.Unix())<<tokenTimestampShift | fraction
+}
+
+func decodeTokenTimestamp(v uint64) time.Time {
+	var (
+		sec  = int64(v >> tokenTimestampShift)
+		nsec = int64(v&0xFFFF) * int64(time.Second) / tokenTimestampFraction
+	)
+	return time.Unix(sec, nsec)
+}
+
+// TokenCodec encrypts and decrypts access tokens with AEAD_AES_128_GCM or
+// AEAD_AES_256_GCM, depending on key length, and with STUN server name as
+// associated data. The key is shared by authorization server and STUN
+// server and is identified by key id, which is USERNAME of requests.
+//
+// RFC 7635 Section 6.2
+type TokenCodec struct {
+	aead   cipher.AEAD
+	server []byte
+}
+
+// NewTokenCodec returns TokenCodec with 16 or 32 byte key for server name.
+func NewTokenCodec(key []byte, server string) (*TokenCodec, error) {
+	switch len(key) {
+	case 16, 32:
+	default:
+		return nil, aes.KeySizeError(len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenCodec{aead: aead, server: []byte(server)}, nil
+}
+
+const (
+	tokenLengthSize    = 2
+	tokenTimestampSize = 8
+	tokenLifetimeSize  = 4
+	maxTokenKeyLength  = 0xFFFF
+)
+
+// Encrypt returns access token with content of t.
+func (c *TokenCodec) Encrypt(t Token) (AccessToken, error) {
+	if len(t.MACKey) > maxTokenKeyLength {
+		return nil, ErrInvalidToken
+	}
+	nonceSize := c.aead.NonceSize()
+	plain := make([]byte, tokenLengthSize+len(t.MACKey)+tokenTimestampSize+tokenLifetimeSize)
+	bin.PutUint16(plain, uint16(len(t.MACKey)))
+	offset := tokenLengthSize + copy(plain[tokenLengthSize:], t.MACKey)
+	bin.PutUint64(plain[offset:], encodeTokenTimestamp(t.Timestamp))
+	offset += tokenTimestampSize
+	bin.PutUint32(plain[offset:], uint32(t.Lifetime/time.Second))
+
+	v := make([]byte, tokenLengthSize+nonceSize, tokenLengthSize+nonceSize+len(plain)+c.aead.Overhead())
+	bin.PutUint16(v, uint16(nonceSize))
+	nonce := v[tokenLengthSize:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(v, nonce, plain, c.server), nil
+}
+
+// Decrypt returns content of access token, returning ErrInvalidToken if
+// token is malformed or is not encrypted with key of codec. Token
+// expiration is not checked.
+func (c *TokenCodec) Decrypt(a AccessToken) (Token, error) {
+	if len(a) < tokenLengthSize {
+		return Token{}, ErrInvalidToken
+	}
+	nonceSize := int(bin.Uint16(a))
+	if nonceSize != c.aead.NonceSize() || len(a) < tokenLengthSize+nonceSize {
+		return Token{}, ErrInvalidToken
+	}
+	nonce := a[tokenLengthSize : tokenLengthSize+nonceSize]
+	plain, err := c.aead.Open(nil, nonce, a[tokenLengthSize+nonceSize:], c.server)
+	if err != nil {
+		return Token{}, ErrInvalidToken
+	}
+	if len(plain) < tokenLengthSize {
+		return Token{}, ErrInvalidToken
+	}
+	keyLength := int(bin.Uint16(plain))
+	if len(plain) != tokenLengthSize+keyLength+tokenTimestampSize+tokenLifetimeSize {
+		return Token{}, ErrInvalidToken
+	}
+	offset := tokenLengthSize + keyLength
+	return Token{
+		MACKey:    plain[tokenLengthSize:offset],
+		Timestamp: decodeTokenTimestamp(bin.Uint64(plain[offset:])),
+		Lifetime:  time.Duration(bin.Uint32(plain[offset+tokenTimestampSize:])) * time.Second,
+	}, nil
+}
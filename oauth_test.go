@@ -0,0 +1,114 @@
+package stun
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestThirdPartyAuthorization(t *testing.T) {
+	m := New()
+	a := NewThirdPartyAuthorization("stun.example.org")
+	if err := a.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got ThirdPartyAuthorization
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != a.String() {
+		t.Errorf("%s (got) != %s (expected)", got, a)
+	}
+	if err := make(ThirdPartyAuthorization, 1024).AddTo(New()); !IsAttrSizeOverflow(err) {
+		t.Errorf("AddTo should return *AttrOverflowErr, got: %v", err)
+	}
+}
+
+func TestTokenTimestamp(t *testing.T) {
+	ts := time.Unix(1433350800, int64(time.Second/2))
+	v := encodeTokenTimestamp(ts)
+	if v != 1433350800<<16|32000 {
+		t.Errorf("unexpected value %x", v)
+	}
+	if got := decodeTokenTimestamp(v); !got.Equal(ts) {
+		t.Errorf("%s (got) != %s (expected)", got, ts)
+	}
+}
+
+func TestTokenCodec(t *testing.T) {
+	const server = "stun.example.org"
+	key := bytes.Repeat([]byte{1}, 32)
+	c, err := NewTokenCodec(key, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := Token{
+		MACKey:    []byte("mac key of token"),
+		Timestamp: time.Unix(1433350800, 0),
+		Lifetime:  time.Hour,
+	}
+	a, err := c.Encrypt(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New()
+	if err = a.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var accessToken AccessToken
+	if err = accessToken.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decrypt(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.MACKey, token.MACKey) || !got.Timestamp.Equal(token.Timestamp) || got.Lifetime != token.Lifetime {
+		t.Errorf("%+v (got) != %+v (expected)", got, token)
+	}
+	if !bytes.Equal(got.Integrity(), NewShortTermIntegrity(string(token.MACKey))) {
+		t.Error("integrity should be keyed by mac key")
+	}
+	if got.Expired(token.Timestamp.Add(time.Minute)) {
+		t.Error("should not be expired")
+	}
+	if !got.Expired(token.Timestamp.Add(time.Hour)) {
+		t.Error("should be expired")
+	}
+	t.Run("OtherServer", func(t *testing.T) {
+		other, err := NewTokenCodec(key, "other.example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = other.Decrypt(a); err != ErrInvalidToken {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("OtherKey", func(t *testing.T) {
+		other, err := NewTokenCodec(bytes.Repeat([]byte{2}, 16), server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = other.Decrypt(a); err != ErrInvalidToken {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		for _, v := range []AccessToken{
+			nil,
+			{0},
+			{0, 12, 1, 2},
+			{0, 3, 1, 2, 3},
+			a[:len(a)-1],
+		} {
+			if _, err := c.Decrypt(v); err != ErrInvalidToken {
+				t.Errorf("%x: unexpected error %v", []byte(v), err)
+			}
+		}
+	})
+	t.Run("BadKey", func(t *testing.T) {
+		if _, err := NewTokenCodec(make([]byte, 24), server); err == nil {
+			t.Error("should error")
+		}
+	})
+}
@@ -94,6 +94,18 @@ func TestRFC5769(t *testing.T) {
 			if err := i.Check(m); err != nil {
 				t.Error(err)
 			}
+			// Password before SASLprep.
+			i, err := NewLongTermIntegrityPrep(
+				"\u30DE\u30C8\u30EA\u30C3\u30AF\u30B9",
+				"example.org",
+				"The\u00ADM\u00AAtr\u2168",
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := i.Check(m); err != nil {
+				t.Error(err)
+			}
 		})
 	})
 	t.Run("Response", func(t *testing.T) {
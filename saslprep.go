@@ -0,0 +1,191 @@
+package stun
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gortc.io/stun/internal/norm"
+)
+
+// ErrProhibitedCharacter means that string contains character that is
+// prohibited by string preparation profile.
+var ErrProhibitedCharacter = errors.New("prohibited character")
+
+// ErrBidiViolation means that string mixes left-to-right and right-to-left
+// characters or starts or ends with non right-to-left character when
+// containing any.
+var ErrBidiViolation = errors.New("bidirectional rule violation")
+
+// ErrEmptyString means that string is empty after preparation.
+var ErrEmptyString = errors.New("empty string")
+
+// saslProhibited is RFC 4013 Section 2.3 prohibited output.
+var saslProhibited = []*unicode.RangeTable{
+	tableC12, tableC21, tableC22, tableC3, tableC4, tableC5,
+	tableC6, tableC7, tableC8, tableC9,
+}
+
+// SASLprep prepares string, like username or password, with SASLprep
+// profile of stringprep for use in MESSAGE-INTEGRITY key, mapping
+// non-ASCII spaces to space, removing characters that are commonly mapped
+// to nothing, normalizing with NFKC and checking prohibited and
+// bidirectional characters. Unassigned code points are allowed as for
+// queries.
+//
+// Normalization is done with Unicode 15.0.0 tables instead of 3.2.
+//
+// RFC 4013
+func SASLprep(s string) (string, error) {
+	if isASCIIPrepared(s) {
+		return s, nil
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case unicode.Is(tableB1, r):
+			continue
+		case unicode.Is(tableC12, r):
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s = norm.NFKC.String(b.String())
+	var randAL, l bool
+	for _, r := range s {
+		if unicode.In(r, saslProhibited...) {
+			return "", ErrProhibitedCharacter
+		}
+		randAL = randAL || unicode.Is(tableD1, r)
+		l = l || unicode.Is(tableD2, r)
+	}
+	if randAL {
+		// RFC 3454 Section 6.
+		first, _ := utf8.DecodeRuneInString(s)
+		last, _ := utf8.DecodeLastRuneInString(s)
+		if l || !unicode.Is(tableD1, first) || !unicode.Is(tableD1, last) {
+			return "", ErrBidiViolation
+		}
+	}
+	return s, nil
+}
+
+// isASCIIPrepared reports whether s consists of printable ASCII characters
+// only, so it is not changed by SASLprep and OpaqueString.
+func isASCIIPrepared(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// OpaqueString prepares string, like password, with OpaqueString profile
+// of PRECIS framework, mapping non-ASCII spaces to space, normalizing
+// with NFC and checking that all characters are allowed by
+// FreeformClass. Returns ErrEmptyString if result is empty.
+//
+// RFC 8265 Section 4.2
+func OpaqueString(s string) (string, error) {
+	if isASCIIPrepared(s) {
+		if s == "" {
+			return "", ErrEmptyString
+		}
+		return s, nil
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if unicode.Is(unicode.Zs, r) {
+			r = ' '
+		}
+		b.WriteRune(r)
+	}
+	s = norm.NFC.String(b.String())
+	if s == "" {
+		return "", ErrEmptyString
+	}
+	for i, r := range s {
+		if !freeformValid(s, i, r) {
+			return "", ErrProhibitedCharacter
+		}
+	}
+	return s, nil
+}
+
+// precisDisallowed are code points of RFC 5892 Section 2.6 exceptions with
+// DISALLOWED derived property.
+var precisDisallowed = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0640, 0x0640, 1},
+		{0x07fa, 0x07fa, 1},
+		{0x302e, 0x302f, 1},
+		{0x3031, 0x3035, 1},
+		{0x303b, 0x303b, 1},
+	},
+}
+
+// oldHangulJamo are conjoining jamo, that are disallowed by PRECIS.
+//
+// RFC 8264 Section 9.9
+var oldHangulJamo = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x11ff, 1},
+		{0xa960, 0xa97c, 1},
+		{0xd7b0, 0xd7c6, 1},
+		{0xd7cb, 0xd7fb, 1},
+	},
+}
+
+// freeformValid reports whether rune r at index i of s is allowed by
+// PRECIS FreeformClass, checking contextual rules of RFC 5892 Appendix A.
+// Joiners are allowed only after virama, joining types are not checked.
+//
+// RFC 8264 Section 4.3
+func freeformValid(s string, i int, r rune) bool {
+	switch r {
+	case 0x200C, 0x200D: // ZERO WIDTH NON-JOINER and JOINER
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		return norm.NFC.PropertiesString(string(before)).CCC() == cccVirama
+	case 0x00B7: // MIDDLE DOT
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+		return before == 'l' && after == 'l'
+	case 0x0375: // GREEK LOWER NUMERAL SIGN (KERAIA)
+		after, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+		return unicode.Is(unicode.Greek, after)
+	case 0x05F3, 0x05F4: // HEBREW PUNCTUATION GERESH and GERSHAYIM
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		return unicode.Is(unicode.Hebrew, before)
+	case 0x30FB: // KATAKANA MIDDLE DOT
+		return strings.IndexFunc(s, func(r rune) bool {
+			return r != 0x30FB && unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han)
+		}) >= 0
+	}
+	switch {
+	case r >= 0x0660 && r <= 0x0669: // ARABIC-INDIC DIGITS
+		return !containsRange(s, 0x06F0, 0x06F9)
+	case r >= 0x06F0 && r <= 0x06F9: // EXTENDED ARABIC-INDIC DIGITS
+		return !containsRange(s, 0x0660, 0x0669)
+	case r == ' ':
+		return true
+	case unicode.In(r, precisDisallowed, oldHangulJamo, unicode.Noncharacter_Code_Point,
+		unicode.Other_Default_Ignorable_Code_Point, unicode.Variation_Selector):
+		return false
+	}
+	return unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S)
+}
+
+// cccVirama is canonical combining class of virama.
+const cccVirama = 9
+
+// containsRange reports whether s contains any rune from lo to hi.
+func containsRange(s string, lo, hi rune) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return r >= lo && r <= hi
+	}) >= 0
+}
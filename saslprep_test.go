@@ -0,0 +1,91 @@
+package stun
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSASLprep(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+		err     error
+	}{
+		// RFC 4013 Section 3.
+		{"I\u00ADX", "IX", nil},
+		{"user", "user", nil},
+		{"USER", "USER", nil},
+		{"ª", "a", nil},
+		{"Ⅸ", "IX", nil},
+		{"\u0007", "", ErrProhibitedCharacter},
+		{"ا1", "", ErrBidiViolation},
+
+		{"", "", nil},
+		{"a\u00A0b", "a b", nil},
+		{"a\u200Bb", "ab", nil},
+		{"\uE000", "", ErrProhibitedCharacter},
+		{"\U000E0001", "", ErrProhibitedCharacter},
+		{"ا1ب", "ا1ب", nil},
+		{"اaب", "", ErrBidiViolation},
+	} {
+		out, err := SASLprep(tc.in)
+		if err != tc.err || out != tc.out {
+			t.Errorf("%+q: %+q, %v (got) != %+q, %v (expected)", tc.in, out, err, tc.out, tc.err)
+		}
+	}
+}
+
+func TestOpaqueString(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+		err     error
+	}{
+		// RFC 8265 Section 4.2.4.
+		{"correct horse battery staple", "correct horse battery staple", nil},
+		{"Correct Horse Battery Staple", "Correct Horse Battery Staple", nil},
+		{"πßå", "πßå", nil},
+		{"Jack of ♦s", "Jack of ♦s", nil},
+		{"foo\u1680bar", "foo bar", nil},
+		{"", "", ErrEmptyString},
+		{"my cat is a \u0009by", "", ErrProhibitedCharacter},
+
+		{"e\u0301", "é", nil},
+		{"Ⅸ", "Ⅸ", nil},
+		{"क\u094D\u200Dष", "क\u094D\u200Dष", nil},
+		{"a\u200Db", "", ErrProhibitedCharacter},
+		{"l·l", "l·l", nil},
+		{"a·b", "", ErrProhibitedCharacter},
+		{"١۱", "", ErrProhibitedCharacter},
+		{"ـ", "", ErrProhibitedCharacter},
+		{"ᄀ", "", ErrProhibitedCharacter},
+		{"\uE000", "", ErrProhibitedCharacter},
+		{"\u2028", "", ErrProhibitedCharacter},
+	} {
+		out, err := OpaqueString(tc.in)
+		if err != tc.err || out != tc.out {
+			t.Errorf("%+q: %+q, %v (got) != %+q, %v (expected)", tc.in, out, err, tc.out, tc.err)
+		}
+	}
+}
+
+func TestNewIntegrityPrep(t *testing.T) {
+	i, err := NewLongTermIntegrityPrep("user", "realm", "pa\u00ADss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(i, NewLongTermIntegrity("user", "realm", "pass")) {
+		t.Error("unexpected long-term key")
+	}
+	if _, err = NewLongTermIntegrityPrep("user\u0007", "realm", "pass"); err != ErrProhibitedCharacter {
+		t.Errorf("unexpected error %v", err)
+	}
+	i, err = NewShortTermIntegrityPrep("Ⅸ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(i, NewShortTermIntegrity("IX")) {
+		t.Error("unexpected short-term key")
+	}
+	if _, err = NewShortTermIntegrityPrep("ا1"); err != ErrBidiViolation {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,328 @@
+// Package server implements STUN server that responds to Binding requests,
+// optionally acting as RFC 5780 NAT behavior discovery server.
+package server
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+
+	"gortc.io/stun"
+)
+
+// Option sets some server option.
+type Option func(s *Server)
+
+// WithSoftware sets SOFTWARE attribute value for responses.
+func WithSoftware(software string) Option {
+	return func(s *Server) {
+		s.software = stun.NewSoftware(software)
+	}
+}
+
+// WithOtherAddress enables RFC 5780 mode, where server additionally listens
+// on the other address (different IP and port), on the primary IP with other
+// port and on the other IP with primary port, honoring CHANGE-REQUEST and
+// reporting OTHER-ADDRESS.
+//
+// If port of primary or other address is zero, the same port is used for
+// both IP addresses.
+func WithOtherAddress(address string) Option {
+	return func(s *Server) {
+		s.otherAddress = address
+	}
+}
+
+// WithClassic enables serving of classic RFC 3489 Binding requests, that
+// have no magic cookie. Responses to such requests contain MAPPED-ADDRESS
+// and SOURCE-ADDRESS instead of XOR-MAPPED-ADDRESS and RESPONSE-ORIGIN,
+// and CHANGED-ADDRESS instead of OTHER-ADDRESS in RFC 5780 mode.
+var WithClassic Option = func(s *Server) {
+	s.classic = true
+}
+
+// Server is STUN server that serves Binding requests over UDP.
+//
+// In RFC 5780 mode server owns four sockets: each combination of two IP
+// addresses and two ports.
+type Server struct {
+	software     stun.Software
+	otherAddress string
+	rfc5780      bool
+	classic      bool
+
+	// conns is [ip][port] matrix of server sockets, only conns[0][0]
+	// is set if not in RFC 5780 mode.
+	conns [2][2]net.PacketConn
+	wg    sync.WaitGroup
+
+	mux    sync.Mutex
+	closed bool
+}
+
+func resolve(network, address string) (*net.UDPAddr, error) {
+	addr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.IP == nil {
+		return nil, &net.AddrError{Err: "IP address required", Addr: address}
+	}
+	return addr, nil
+}
+
+// Listen announces on the local UDP network address and starts serving.
+// Call Close to stop server.
+func Listen(network, address string, options ...Option) (*Server, error) {
+	s := new(Server)
+	for _, o := range options {
+		o(s)
+	}
+	if s.otherAddress == "" {
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		s.conns[0][0] = conn
+		s.start()
+		return s, nil
+	}
+	s.rfc5780 = true
+	primary, err := resolve(network, address)
+	if err != nil {
+		return nil, err
+	}
+	other, err := resolve(network, s.otherAddress)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.listen(network, [2]net.IP{primary.IP, other.IP}, [2]int{primary.Port, other.Port}); err != nil {
+		s.closeConns()
+		return nil, err
+	}
+	s.start()
+	return s, nil
+}
+
+// listen binds all four sockets, resolving zero ports from the
+// sockets on first IP address.
+func (s *Server) listen(network string, ips [2]net.IP, ports [2]int) error {
+	for port := range ports {
+		for ip := range ips {
+			addr := &net.UDPAddr{IP: ips[ip], Port: ports[port]}
+			conn, err := net.ListenPacket(network, net.JoinHostPort(addr.IP.String(), strconv.Itoa(addr.Port)))
+			if err != nil {
+				return err
+			}
+			s.conns[ip][port] = conn
+			if ports[port] == 0 {
+				ports[port] = conn.LocalAddr().(*net.UDPAddr).Port
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) start() {
+	for ip := range s.conns {
+		for port := range s.conns[ip] {
+			if s.conns[ip][port] == nil {
+				continue
+			}
+			s.wg.Add(1)
+			go s.serve(ip, port)
+		}
+	}
+}
+
+// Addr returns primary address of server.
+func (s *Server) Addr() net.Addr {
+	return s.conns[0][0].LocalAddr()
+}
+
+// OtherAddr returns other address of server in RFC 5780 mode or nil.
+func (s *Server) OtherAddr() net.Addr {
+	if !s.rfc5780 {
+		return nil
+	}
+	return s.conns[1][1].LocalAddr()
+}
+
+func (s *Server) closeConns() error {
+	var closeErr error
+	for ip := range s.conns {
+		for port := range s.conns[ip] {
+			if s.conns[ip][port] == nil {
+				continue
+			}
+			if err := s.conns[ip][port].Close(); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+	}
+	return closeErr
+}
+
+// ErrServerClosed means that server is already closed.
+var ErrServerClosed = errors.New("server is closed")
+
+// Close stops server, closing all sockets.
+func (s *Server) Close() error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return ErrServerClosed
+	}
+	s.closed = true
+	s.mux.Unlock()
+	err := s.closeConns()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve(ip, port int) {
+	defer s.wg.Done()
+	var (
+		conn = s.conns[ip][port]
+		buf  = make([]byte, 1500)
+		req  = new(stun.Message)
+		res  = new(stun.Message)
+	)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req.Raw = append(req.Raw[:0], buf[:n]...)
+		switch {
+		case stun.IsMessage(buf[:n]):
+			err = req.Decode()
+		case s.classic && stun.IsClassicMessage(buf[:n]):
+			err = req.DecodeClassic()
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		from, to, err := s.process(ip, port, udpAddr, req, res)
+		if err != nil {
+			continue
+		}
+		// Errors are ignored, the client will retransmit the request.
+		_, _ = s.conns[from[0]][from[1]].WriteTo(res.Raw, to)
+	}
+}
+
+// process builds response for request received on conns[ip][port] from
+// addr, returning the response source socket indexes and destination.
+func (s *Server) process(ip, port int, addr *net.UDPAddr, req, res *stun.Message) (from [2]int, to *net.UDPAddr, err error) {
+	if req.Type.Method != stun.MethodBinding || req.Type.Class != stun.ClassRequest {
+		return from, nil, errNotBinding
+	}
+	from = [2]int{ip, port}
+	to = addr
+	var (
+		unknown  stun.UnknownAttributes
+		change   stun.ChangeRequest
+		respPort stun.ResponsePort
+		padding  stun.Padding
+	)
+	for _, a := range req.Attributes {
+		if a.Type.Optional() {
+			continue
+		}
+		switch a.Type {
+		case stun.AttrFingerprint, stun.AttrPadding, stun.AttrResponsePort:
+			continue
+		case stun.AttrChangeRequest:
+			if s.rfc5780 {
+				continue
+			}
+		}
+		unknown = append(unknown, a.Type)
+	}
+	if len(unknown) > 0 {
+		return from, to, s.buildError(req, res, stun.CodeUnknownAttribute, unknown)
+	}
+	if err = change.GetFrom(req); err != nil && err != stun.ErrAttributeNotFound {
+		return from, to, s.buildError(req, res, stun.CodeBadRequest)
+	}
+	if change.ChangeIP {
+		from[0] = 1 - ip
+	}
+	if change.ChangePort {
+		from[1] = 1 - port
+	}
+	if err = respPort.GetFrom(req); err == nil {
+		to = &net.UDPAddr{IP: addr.IP, Port: int(respPort), Zone: addr.Zone}
+	} else if err != stun.ErrAttributeNotFound {
+		return from, to, s.buildError(req, res, stun.CodeBadRequest)
+	}
+	if req.IsClassic() {
+		return from, to, s.build(req, res, s.classicSetters(ip, port, from, addr, req)...)
+	}
+	setters := []stun.Setter{
+		req, stun.BindingSuccess,
+		&stun.XORMappedAddress{IP: addr.IP, Port: addr.Port},
+	}
+	if s.rfc5780 {
+		origin := s.conns[from[0]][from[1]].LocalAddr().(*net.UDPAddr)
+		other := s.conns[1-ip][1-port].LocalAddr().(*net.UDPAddr)
+		setters = append(setters,
+			&stun.ResponseOrigin{IP: origin.IP, Port: origin.Port},
+			&stun.OtherAddress{IP: other.IP, Port: other.Port},
+		)
+	}
+	if err = s.build(req, res, setters...); err != nil {
+		return from, to, err
+	}
+	if padding.GetFrom(req) != nil {
+		return from, to, nil
+	}
+	// Padding response to the request size, so both have the same
+	// chance to be fragmented. RFC 5780 Section 7.6
+	n := len(req.Raw) - len(res.Raw) - 4 // attribute header
+	if n <= 0 {
+		return from, to, nil
+	}
+	return from, to, s.build(req, res, append(setters, stun.NewPadding(n))...)
+}
+
+var errNotBinding = errors.New("not a binding request")
+
+// classicSetters returns setters of response to classic RFC 3489 request.
+//
+// RFC 3489 Section 8.1
+func (s *Server) classicSetters(ip, port int, from [2]int, addr *net.UDPAddr, req *stun.Message) []stun.Setter {
+	origin := s.conns[from[0]][from[1]].LocalAddr().(*net.UDPAddr)
+	setters := []stun.Setter{
+		req, stun.BindingSuccess,
+		&stun.MappedAddress{IP: addr.IP, Port: addr.Port},
+		&stun.SourceAddress{IP: origin.IP, Port: origin.Port},
+	}
+	if s.rfc5780 {
+		other := s.conns[1-ip][1-port].LocalAddr().(*net.UDPAddr)
+		setters = append(setters, &stun.ChangedAddress{IP: other.IP, Port: other.Port})
+	}
+	return setters
+}
+
+func (s *Server) build(req, res *stun.Message, setters ...stun.Setter) error {
+	if len(s.software) > 0 {
+		setters = append(setters, &s.software)
+	}
+	if req.Contains(stun.AttrFingerprint) {
+		setters = append(setters, stun.Fingerprint)
+	}
+	return res.Build(setters...)
+}
+
+func (s *Server) buildError(req, res *stun.Message, code stun.ErrorCode, setters ...stun.Setter) error {
+	return s.build(req, res, append([]stun.Setter{req, stun.BindingError, code}, setters...)...)
+}
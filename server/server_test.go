@@ -0,0 +1,326 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+func listenRFC5780(t *testing.T, options ...Option) *Server {
+	t.Helper()
+	// Loopback has no aliases by default on macOS.
+	c, err := net.ListenPacket("udp4", "127.0.0.2:0")
+	if err != nil {
+		t.Skip("127.0.0.2 is not available:", err)
+	}
+	_ = c.Close()
+	for attempt := 0; attempt < 10; attempt++ {
+		var s *Server
+		s, err = Listen("udp4", "127.0.0.1:0", append(options, WithOtherAddress("127.0.0.2:0"))...)
+		if err == nil {
+			return s
+		}
+	}
+	t.Fatal(err)
+	return nil
+}
+
+// do sends request from conn to addr and returns response and its source.
+func do(t *testing.T, conn net.PacketConn, addr net.Addr, setters ...stun.Setter) (*stun.Message, net.Addr) {
+	t.Helper()
+	req := stun.MustBuild(append([]stun.Setter{stun.TransactionID, stun.BindingRequest}, setters...)...)
+	if _, err := conn.WriteTo(req.Raw, addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, from, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := new(stun.Message)
+	if _, err = res.Write(buf[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if res.TransactionID != req.TransactionID {
+		t.Fatal("transaction id mismatch")
+	}
+	return res, from
+}
+
+func listenClient(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestServer_Binding(t *testing.T) {
+	s, err := Listen("udp4", "127.0.0.1:0", WithSoftware("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.OtherAddr() != nil {
+		t.Error("unexpected other address")
+	}
+	conn := listenClient(t)
+	defer conn.Close()
+	res, _ := do(t, conn, s.Addr(), stun.Fingerprint)
+	if res.Type != stun.BindingSuccess {
+		t.Fatal("unexpected type", res.Type)
+	}
+	var (
+		addr     stun.XORMappedAddress
+		software stun.Software
+	)
+	if err = res.Parse(&addr, &software); err != nil {
+		t.Fatal(err)
+	}
+	local := conn.LocalAddr().(*net.UDPAddr)
+	if !addr.IP.Equal(local.IP) || addr.Port != local.Port {
+		t.Errorf("bad mapped address %s", addr)
+	}
+	if software.String() != "test" {
+		t.Errorf("bad software %q", software)
+	}
+	if err = stun.Fingerprint.Check(res); err != nil {
+		t.Error(err)
+	}
+	if res.Contains(stun.AttrOtherAddress) {
+		t.Error("unexpected OTHER-ADDRESS")
+	}
+	t.Run("ChangeRequest", func(t *testing.T) {
+		res, _ := do(t, conn, s.Addr(), stun.ChangeRequest{ChangeIP: true})
+		if res.Type != stun.BindingError {
+			t.Fatal("unexpected type", res.Type)
+		}
+		var (
+			code    stun.ErrorCodeAttribute
+			unknown stun.UnknownAttributes
+		)
+		if err := res.Parse(&code, &unknown); err != nil {
+			t.Fatal(err)
+		}
+		if code.Code != stun.CodeUnknownAttribute {
+			t.Errorf("unexpected code %s", code)
+		}
+		if len(unknown) != 1 || unknown[0] != stun.AttrChangeRequest {
+			t.Errorf("unexpected unknown attributes %s", unknown)
+		}
+	})
+	if err = s.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = s.Close(); err != ErrServerClosed {
+		t.Error("second close should fail")
+	}
+}
+
+func TestServer_RFC5780(t *testing.T) {
+	s := listenRFC5780(t)
+	defer s.Close()
+	var (
+		primary = s.Addr().(*net.UDPAddr)
+		other   = s.OtherAddr().(*net.UDPAddr)
+	)
+	if primary.IP.Equal(other.IP) || primary.Port == other.Port {
+		t.Fatalf("bad addresses %s and %s", primary, other)
+	}
+	conn := listenClient(t)
+	defer conn.Close()
+	for _, tc := range []struct {
+		name   string
+		change stun.ChangeRequest
+		from   *net.UDPAddr
+	}{
+		{"NoChange", stun.ChangeRequest{}, primary},
+		{"ChangeIP", stun.ChangeRequest{ChangeIP: true}, &net.UDPAddr{IP: other.IP, Port: primary.Port}},
+		{"ChangePort", stun.ChangeRequest{ChangePort: true}, &net.UDPAddr{IP: primary.IP, Port: other.Port}},
+		{"ChangeBoth", stun.ChangeRequest{ChangeIP: true, ChangePort: true}, other},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, from := do(t, conn, primary, tc.change)
+			if from.String() != tc.from.String() {
+				t.Errorf("response from %s, expected %s", from, tc.from)
+			}
+			var (
+				origin   stun.ResponseOrigin
+				otherGot stun.OtherAddress
+			)
+			if err := res.Parse(&origin, &otherGot); err != nil {
+				t.Fatal(err)
+			}
+			if origin.String() != tc.from.String() {
+				t.Errorf("RESPONSE-ORIGIN %s, expected %s", origin, tc.from)
+			}
+			if otherGot.String() != other.String() {
+				t.Errorf("OTHER-ADDRESS %s, expected %s", otherGot, other)
+			}
+		})
+	}
+	t.Run("OtherAddress", func(t *testing.T) {
+		_, from := do(t, conn, other)
+		var otherGot stun.OtherAddress
+		res, _ := do(t, conn, other)
+		if err := otherGot.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if from.String() != other.String() || otherGot.String() != primary.String() {
+			t.Errorf("unexpected addresses %s, %s", from, otherGot)
+		}
+	})
+	t.Run("ResponsePort", func(t *testing.T) {
+		target := listenClient(t)
+		defer target.Close()
+		port := target.LocalAddr().(*net.UDPAddr).Port
+		req := stun.MustBuild(stun.TransactionID, stun.BindingRequest, stun.ResponsePort(port), stun.NewPadding(64))
+		if _, err := conn.WriteTo(req.Raw, primary); err != nil {
+			t.Fatal(err)
+		}
+		if err := target.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1500)
+		n, _, err := target.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := new(stun.Message)
+		if _, err = res.Write(buf[:n]); err != nil {
+			t.Fatal(err)
+		}
+		var (
+			addr    stun.XORMappedAddress
+			padding stun.Padding
+		)
+		if err = res.Parse(&addr, &padding); err != nil {
+			t.Fatal(err)
+		}
+		if addr.String() != conn.LocalAddr().String() {
+			t.Errorf("bad mapped address %s", addr)
+		}
+		if len(padding) == 0 || len(res.Raw) != len(req.Raw) {
+			t.Errorf("response size %d != request size %d", len(res.Raw), len(req.Raw))
+		}
+	})
+	t.Run("DiscoverNAT", func(t *testing.T) {
+		res, err := stun.DiscoverNAT(conn, primary, stun.WithRTO(time.Millisecond*5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.NAT {
+			t.Error("should be no NAT")
+		}
+		if res.Mapping != stun.BehaviorEndpointIndependent || res.Filtering != stun.BehaviorEndpointIndependent {
+			t.Errorf("unexpected behavior: %s mapping, %s filtering", res.Mapping, res.Filtering)
+		}
+	})
+}
+
+func TestServer_NotBinding(t *testing.T) {
+	s, err := Listen("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn := listenClient(t)
+	defer conn.Close()
+	if _, err = conn.WriteTo(stun.MustBuild(stun.TransactionID, stun.NewType(stun.MethodBinding, stun.ClassIndication)).Raw, s.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	// Binding request after ignored indication should be served.
+	res, _ := do(t, conn, s.Addr())
+	if res.Type != stun.BindingSuccess {
+		t.Error("unexpected type", res.Type)
+	}
+}
+
+func TestServer_Classic(t *testing.T) {
+	s := listenRFC5780(t, WithClassic)
+	defer s.Close()
+	var (
+		primary = s.Addr().(*net.UDPAddr)
+		other   = s.OtherAddr().(*net.UDPAddr)
+		id      = stun.NewClassicTransactionID()
+		from    = &net.UDPAddr{IP: primary.IP, Port: other.Port}
+	)
+	conn := listenClient(t)
+	defer conn.Close()
+	req := stun.MustBuild(id, stun.BindingRequest, stun.ChangeRequest{ChangePort: true})
+	if _, err := conn.WriteTo(req.Raw, primary); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, addr, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != from.String() {
+		t.Errorf("response from %s, expected %s", addr, from)
+	}
+	res := new(stun.Message)
+	if err = stun.DecodeClassic(buf[:n], res); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		resID   stun.ClassicTransactionID
+		mapped  stun.MappedAddress
+		source  stun.SourceAddress
+		changed stun.ChangedAddress
+	)
+	if err = res.Parse(&resID, &mapped, &source, &changed); err != nil {
+		t.Fatal(err)
+	}
+	if resID != id {
+		t.Error("transaction id mismatch")
+	}
+	if res.Type != stun.BindingSuccess {
+		t.Error("unexpected type", res.Type)
+	}
+	if mapped.String() != conn.LocalAddr().String() {
+		t.Errorf("bad MAPPED-ADDRESS %s", mapped)
+	}
+	if source.String() != from.String() {
+		t.Errorf("bad SOURCE-ADDRESS %s", source)
+	}
+	if changed.String() != other.String() {
+		t.Errorf("bad CHANGED-ADDRESS %s", changed)
+	}
+	t.Run("Disabled", func(t *testing.T) {
+		s, err := Listen("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if _, err = conn.WriteTo(stun.MustBuild(id, stun.BindingRequest).Raw, s.Addr()); err != nil {
+			t.Fatal(err)
+		}
+		// Classic request should be ignored.
+		res, _ := do(t, conn, s.Addr())
+		if res.Type != stun.BindingSuccess {
+			t.Error("unexpected type", res.Type)
+		}
+	})
+}
+
+func TestListenErrors(t *testing.T) {
+	if _, err := Listen("udp4", "127.0.0.1:0", WithOtherAddress("bad address")); err == nil {
+		t.Error("should error")
+	}
+	if _, err := Listen("udp4", ":0", WithOtherAddress("127.0.0.2:0")); err == nil {
+		t.Error("should error")
+	}
+	if _, err := Listen("udp4", "bad address"); err == nil {
+		t.Error("should error")
+	}
+}
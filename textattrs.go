@@ -1,5 +1,7 @@
 package stun
 
+import "strings"
+
 // NewUsername returns Username with provided value.
 func NewUsername(username string) Username {
 	return Username(username)
@@ -105,6 +107,72 @@ func (n *Nonce) GetFrom(m *Message) error {
 	return (*TextAttribute)(n).GetFromAs(m, AttrNonce)
 }
 
+// Origin represents ORIGIN attribute, which is origin of web application
+// that initiated request, like "https://example.com".
+//
+// Draft: An Origin Attribute for the STUN Protocol, Section 3
+type Origin []byte
+
+// NewOrigin returns new Origin from string.
+func NewOrigin(origin string) Origin {
+	return Origin(origin)
+}
+
+func (o Origin) String() string {
+	return string(o)
+}
+
+const maxOriginB = 763
+
+// AddTo adds ORIGIN to message.
+func (o Origin) AddTo(m *Message) error {
+	return TextAttribute(o).AddToAs(m, AttrOrigin, maxOriginB)
+}
+
+// GetFrom gets first ORIGIN from message.
+func (o *Origin) GetFrom(m *Message) error {
+	return (*TextAttribute)(o).GetFromAs(m, AttrOrigin)
+}
+
+// Origins represents all ORIGIN attributes of message, request can
+// contain multiple origins, e.g. when it is proxied.
+type Origins []Origin
+
+func (o Origins) String() string {
+	s := make([]string, len(o))
+	for i := range o {
+		s[i] = o[i].String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// AddTo adds ORIGIN for each origin to message.
+func (o Origins) AddTo(m *Message) error {
+	for i := range o {
+		if err := o[i].AddTo(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFrom gets all ORIGIN attributes from message in order of appearance,
+// returning ErrAttributeNotFound if there are none. Values are valid until
+// m.Raw is valid.
+func (o *Origins) GetFrom(m *Message) error {
+	origins := (*o)[:0]
+	for _, a := range m.Attributes {
+		if a.Type == AttrOrigin {
+			origins = append(origins, Origin(a.Value))
+		}
+	}
+	*o = origins
+	if len(origins) == 0 {
+		return ErrAttributeNotFound
+	}
+	return nil
+}
+
 // TextAttribute is helper for adding and getting text attributes.
 type TextAttribute []byte
 
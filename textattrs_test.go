@@ -261,3 +261,51 @@ func BenchmarkNonce_GetFrom(b *testing.B) {
 		n.GetFrom(m)
 	}
 }
+
+func TestOrigin(t *testing.T) {
+	m := New()
+	o := NewOrigin("http://localhost:3000")
+	if err := o.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got Origin
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != o.String() {
+		t.Errorf("Expected %q, got %q.", o, got)
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		if err := make(Origin, 1024).AddTo(New()); !IsAttrSizeOverflow(err) {
+			t.Errorf("AddTo should return *AttrOverflowErr, got: %v", err)
+		}
+	})
+}
+
+func TestOrigins(t *testing.T) {
+	m := New()
+	origins := Origins{NewOrigin("https://example.com"), NewOrigin("https://example.org")}
+	if err := origins.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got Origins
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "https://example.com, https://example.org" {
+		t.Errorf("unexpected origins %q", got)
+	}
+	var first Origin
+	if err := first.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != "https://example.com" {
+		t.Errorf("unexpected first origin %q", first)
+	}
+	if err := got.GetFrom(New()); err != ErrAttributeNotFound {
+		t.Errorf("GetFrom should return %q, got: %v", ErrAttributeNotFound, err)
+	}
+	if err := (Origins{make(Origin, 1024)}).AddTo(New()); !IsAttrSizeOverflow(err) {
+		t.Errorf("AddTo should return *AttrOverflowErr, got: %v", err)
+	}
+}
@@ -0,0 +1,832 @@
+// Package turn implements Traversal Using Relays around NAT (TURN)
+// [RFC 5766] client and server that are built on STUN.
+package turn
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"gortc.io/stun"
+	"gortc.io/stun/internal/clockutil"
+)
+
+// Lifetimes of permissions and channel bindings.
+//
+// RFC 5766 Section 8 and Section 11
+const (
+	PermissionLifetime     = time.Minute * 5
+	ChannelBindingLifetime = time.Minute * 10
+)
+
+// refreshMargin is time before expiration when allocation, permission or
+// channel binding is refreshed.
+const refreshMargin = time.Minute
+
+// refreshAfter returns duration after which entity with lifetime d should
+// be refreshed.
+func refreshAfter(d time.Duration) time.Duration {
+	if d > 2*refreshMargin {
+		return d - refreshMargin
+	}
+	return d / 2
+}
+
+// DefaultRefreshRate is rate of default refresh ticker.
+const DefaultRefreshRate = time.Second
+
+// Message types of TURN methods.
+var (
+	allocateRequest             = stun.NewType(stun.MethodAllocate, stun.ClassRequest)
+	refreshRequest              = stun.NewType(stun.MethodRefresh, stun.ClassRequest)
+	createPermissionRequest     = stun.NewType(stun.MethodCreatePermission, stun.ClassRequest)
+	channelBindRequest          = stun.NewType(stun.MethodChannelBind, stun.ClassRequest)
+	sendIndication              = stun.NewType(stun.MethodSend, stun.ClassIndication)
+	dataIndication              = stun.NewType(stun.MethodData, stun.ClassIndication)
+	connectRequest              = stun.NewType(stun.MethodConnect, stun.ClassRequest)
+	connectionBindRequest       = stun.NewType(stun.MethodConnectionBind, stun.ClassRequest)
+	connectionAttemptIndication = stun.NewType(stun.MethodConnectionAttempt, stun.ClassIndication)
+)
+
+// ClientOption sets some client option.
+type ClientOption func(c *Client)
+
+// WithCredentials sets long-term credentials of client.
+func WithCredentials(username, password string) ClientOption {
+	return func(c *Client) {
+		c.username = stun.NewUsername(username)
+		c.password = password
+	}
+}
+
+// WithAccessToken sets third-party authorization credentials of client,
+// where kid is key id of token, token is ACCESS-TOKEN and macKey is key of
+// MESSAGE-INTEGRITY, all obtained from authorization server.
+//
+// RFC 7635 Section 4.1
+func WithAccessToken(kid string, token stun.AccessToken, macKey []byte) ClientOption {
+	return func(c *Client) {
+		c.username = stun.NewUsername(kid)
+		c.token = token
+		c.macKey = macKey
+	}
+}
+
+// WithLifetime sets requested lifetime of allocation, server default is
+// used if not set.
+func WithLifetime(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.lifetime = d
+	}
+}
+
+// WithSTUNOptions sets options of underlying STUN client.
+func WithSTUNOptions(options ...stun.ClientOption) ClientOption {
+	return func(c *Client) {
+		c.stunOptions = append(c.stunOptions, options...)
+	}
+}
+
+// WithClock sets Clock of client, the source of current time for refresh
+// timers.
+func WithClock(clock stun.Clock) ClientOption {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithCollector sets collector that drives refresh timers, which is
+// ticker with DefaultRefreshRate by default.
+func WithCollector(coll stun.Collector) ClientOption {
+	return func(c *Client) {
+		c.collector = coll
+	}
+}
+
+// packet is data received from peer through relay.
+type packet struct {
+	data []byte
+	addr *net.UDPAddr
+}
+
+// channel is channel binding to peer.
+type channel struct {
+	number    stun.ChannelNumber
+	peer      *net.UDPAddr
+	refreshAt time.Time
+}
+
+// pendingBind is channel binding in progress, done is closed when
+// binding is completed with err.
+type pendingBind struct {
+	ch   *channel
+	done chan struct{}
+	err  error
+}
+
+// Client is TURN client that allocates relayed transport address on server
+// and exposes it as net.PacketConn. Data is sent to peers with Send
+// indications or via ChannelData if channel is bound to peer.
+//
+// Permissions are created on first write to peer IP, allocation,
+// permissions and channel bindings are refreshed automatically until Close.
+type Client struct {
+	conn        stun.Connection
+	packet      net.PacketConn // nil for TCP allocation
+	stun        *stun.Client
+	stunOptions []stun.ClientOption
+	clock       stun.Clock
+	collector   stun.Collector
+	username    stun.Username
+	password    string
+	token       stun.AccessToken
+	macKey      []byte
+	lifetime    time.Duration
+	relayed     stun.XORRelayedAddress
+	mapped      stun.XORMappedAddress
+
+	// authMux guards realm, nonce and integrity.
+	authMux   sync.Mutex
+	realm     stun.Realm
+	nonce     stun.Nonce
+	integrity stun.MessageIntegrity
+
+	mux        sync.Mutex
+	refreshAt  time.Time               // of allocation
+	perms      map[string]time.Time    // refresh time by peer IP
+	channels   map[string]*channel     // by peer address
+	pending    map[string]*pendingBind // by peer address
+	numbers    map[stun.ChannelNumber]*channel
+	nextNumber stun.ChannelNumber
+	free       []stun.ChannelNumber // released after failed binding
+	deadline   time.Time
+	// deadlineChanged is closed when read deadline is changed.
+	deadlineChanged chan struct{}
+	closed          bool
+
+	data chan packet
+	done chan struct{}
+}
+
+// dataQueueSize is size of received data queue, data is dropped if queue
+// is full.
+const dataQueueSize = 64
+
+// Allocate requests UDP allocation from server over conn, which must not be
+// read by anything else until Close is called. Conn is not closed on Close.
+//
+// RFC 5766 Section 6
+func Allocate(conn net.PacketConn, server net.Addr, options ...ClientOption) (*Client, error) {
+	c := newClient(options)
+	c.packet = conn
+	c.conn = &serverConn{
+		conn:   conn,
+		server: server,
+		client: c,
+		buf:    make([]byte, 0xFFFF),
+		m:      new(stun.Message),
+	}
+	if err := c.allocate(stun.ProtoUDP); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newClient(options []ClientOption) *Client {
+	c := &Client{
+		clock:           clockutil.System{},
+		perms:           make(map[string]time.Time),
+		channels:        make(map[string]*channel),
+		pending:         make(map[string]*pendingBind),
+		numbers:         make(map[stun.ChannelNumber]*channel),
+		nextNumber:      stun.MinChannelNumber,
+		deadlineChanged: make(chan struct{}),
+		data:            make(chan packet, dataQueueSize),
+		done:            make(chan struct{}),
+	}
+	for _, o := range options {
+		o(c)
+	}
+	return c
+}
+
+// allocate requests allocation with relayed transport protocol over
+// c.conn and starts refresh timers.
+func (c *Client) allocate(protocol stun.Protocol) error {
+	client, err := stun.NewClient(c.conn, c.stunOptions...)
+	if err != nil {
+		return err
+	}
+	c.stun = client
+	setters := []stun.Setter{stun.RequestedTransport{Protocol: protocol}}
+	if c.lifetime > 0 {
+		setters = append(setters, stun.Lifetime{Duration: c.lifetime})
+	}
+	res, err := c.do(allocateRequest, setters...)
+	if err != nil {
+		return c.closeSTUN(err)
+	}
+	var lifetime stun.Lifetime
+	if err = res.Parse(&c.relayed, &lifetime); err != nil {
+		return c.closeSTUN(err)
+	}
+	if err = c.mapped.GetFrom(res); err != nil && err != stun.ErrAttributeNotFound {
+		return c.closeSTUN(err)
+	}
+	c.refreshAt = c.clock.Now().Add(refreshAfter(lifetime.Duration))
+	if c.collector == nil {
+		c.collector = clockutil.NewCollector(c.clock)
+	}
+	if err = c.collector.Start(DefaultRefreshRate, c.collect); err != nil {
+		return c.closeSTUN(err)
+	}
+	return nil
+}
+
+// closeSTUN closes STUN client, resetting read deadline of packet conn.
+// Returns err if not nil or first error that occurred.
+func (c *Client) closeSTUN(err error) error {
+	closeErr := c.stun.Close()
+	if c.packet != nil {
+		if deadlineErr := c.packet.SetReadDeadline(time.Time{}); err == nil {
+			err = deadlineErr
+		}
+	}
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// maxAuthAttempts is maximum number of attempts to authenticate request
+// on 401 and 438 error responses.
+const maxAuthAttempts = 3
+
+// ErrUnexpectedResponse means that response has unexpected message type.
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// do performs request of type t with long-term credentials, handling 401
+// and 438 error responses. Other error responses are returned as
+// stun.ResponseErr.
+func (c *Client) do(t stun.MessageType, setters ...stun.Setter) (*stun.Message, error) {
+	return c.doWith(c.transact, t, setters...)
+}
+
+// transact performs transaction via STUN client.
+func (c *Client) transact(req *stun.Message) (*stun.Message, error) {
+	var (
+		res      = new(stun.Message)
+		eventErr error
+	)
+	if err := c.stun.Do(req, func(e stun.Event) {
+		if e.Error != nil {
+			eventErr = e.Error
+			return
+		}
+		eventErr = e.Message.CloneTo(res)
+	}); err != nil {
+		return nil, err
+	}
+	if eventErr != nil {
+		return nil, eventErr
+	}
+	return res, nil
+}
+
+// doWith is like do, but performs transactions with transact.
+func (c *Client) doWith(transact func(req *stun.Message) (*stun.Message, error), t stun.MessageType, setters ...stun.Setter) (*stun.Message, error) {
+	var code stun.ErrorCodeAttribute
+	for attempt := 0; attempt < maxAuthAttempts; attempt++ {
+		req, integrity, err := c.build(t, setters...)
+		if err != nil {
+			return nil, err
+		}
+		res, err := transact(req)
+		if err != nil {
+			return nil, err
+		}
+		if res.Type.Method != t.Method {
+			return nil, ErrUnexpectedResponse
+		}
+		switch res.Type.Class {
+		case stun.ClassSuccessResponse:
+			if integrity != nil && res.Contains(stun.AttrMessageIntegrity) {
+				if err = integrity.Check(res); err != nil {
+					return nil, err
+				}
+			}
+			return res, nil
+		case stun.ClassErrorResponse:
+		default:
+			return nil, ErrUnexpectedResponse
+		}
+		if err = code.GetFrom(res); err != nil {
+			return nil, err
+		}
+		if code.Code != stun.CodeUnauthorized && code.Code != stun.CodeStaleNonce {
+			return nil, stun.ResponseErr{Code: code}
+		}
+		if len(c.username) == 0 {
+			return nil, stun.ResponseErr{Code: code}
+		}
+		if err = c.challenge(res); err != nil {
+			return nil, err
+		}
+	}
+	return nil, stun.ResponseErr{Code: code}
+}
+
+// build builds request of type t, authenticating it if challenge was
+// received.
+func (c *Client) build(t stun.MessageType, setters ...stun.Setter) (*stun.Message, stun.MessageIntegrity, error) {
+	c.authMux.Lock()
+	var (
+		realm     = c.realm
+		nonce     = c.nonce
+		integrity = c.integrity
+	)
+	c.authMux.Unlock()
+	s := append([]stun.Setter{stun.TransactionID, t}, setters...)
+	if integrity != nil {
+		s = append(s, c.username, realm, nonce)
+		if c.token != nil {
+			s = append(s, c.token)
+		}
+		s = append(s, integrity)
+	}
+	m, err := stun.Build(append(s, stun.Fingerprint)...)
+	return m, integrity, err
+}
+
+// challenge updates realm, nonce and integrity from error response.
+//
+// RFC 5389 Section 10.2.3
+func (c *Client) challenge(res *stun.Message) error {
+	var (
+		realm stun.Realm
+		nonce stun.Nonce
+	)
+	if err := nonce.GetFrom(res); err != nil {
+		return err
+	}
+	c.authMux.Lock()
+	defer c.authMux.Unlock()
+	if err := realm.GetFrom(res); err == nil {
+		c.realm = append(c.realm[:0], realm...)
+	} else if err != stun.ErrAttributeNotFound || c.integrity == nil {
+		// Realm can be omitted only in 438 response.
+		return err
+	}
+	c.nonce = append(stun.Nonce{}, nonce...)
+	if c.token != nil {
+		c.integrity = stun.Token{MACKey: c.macKey}.Integrity()
+	} else {
+		c.integrity = stun.NewLongTermIntegrity(c.username.String(), c.realm.String(), c.password)
+	}
+	return nil
+}
+
+// Relayed returns relayed transport address of allocation.
+func (c *Client) Relayed() *net.UDPAddr {
+	return &net.UDPAddr{IP: c.relayed.IP, Port: c.relayed.Port}
+}
+
+// Mapped returns server-reflexive address of client or nil if server did
+// not include XOR-MAPPED-ADDRESS.
+func (c *Client) Mapped() *net.UDPAddr {
+	if c.mapped.IP == nil {
+		return nil
+	}
+	return &net.UDPAddr{IP: c.mapped.IP, Port: c.mapped.Port}
+}
+
+// LocalAddr returns relayed transport address.
+func (c *Client) LocalAddr() net.Addr {
+	return c.Relayed()
+}
+
+// refresh refreshes allocation with requested lifetime, server default is
+// used if not set, because zero lifetime deletes allocation.
+//
+// RFC 5766 Section 7
+func (c *Client) refresh() error {
+	var setters []stun.Setter
+	if c.lifetime > 0 {
+		setters = append(setters, stun.Lifetime{Duration: c.lifetime})
+	}
+	res, err := c.do(refreshRequest, setters...)
+	if err != nil {
+		return err
+	}
+	var lifetime stun.Lifetime
+	if err = lifetime.GetFrom(res); err != nil {
+		return err
+	}
+	c.mux.Lock()
+	c.refreshAt = c.clock.Now().Add(refreshAfter(lifetime.Duration))
+	c.mux.Unlock()
+	return nil
+}
+
+func peerAddr(addr net.Addr) (*net.UDPAddr, error) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a, nil
+	case *net.TCPAddr:
+		return &net.UDPAddr{IP: a.IP, Port: a.Port, Zone: a.Zone}, nil
+	default:
+		return nil, net.UnknownNetworkError(addr.Network())
+	}
+}
+
+// CreatePermission installs or refreshes permissions for IP addresses of
+// peers, ports are ignored.
+//
+// RFC 5766 Section 9
+func (c *Client) CreatePermission(peers ...net.Addr) error {
+	var (
+		setters = make([]stun.Setter, 0, len(peers))
+		ips     = make([]string, 0, len(peers))
+	)
+	for _, addr := range peers {
+		peer, err := peerAddr(addr)
+		if err != nil {
+			return err
+		}
+		setters = append(setters, stun.XORPeerAddress{IP: peer.IP})
+		ips = append(ips, peer.IP.String())
+	}
+	if _, err := c.do(createPermissionRequest, setters...); err != nil {
+		return err
+	}
+	refreshAt := c.clock.Now().Add(refreshAfter(PermissionLifetime))
+	c.mux.Lock()
+	for _, ip := range ips {
+		c.perms[ip] = refreshAt
+	}
+	c.mux.Unlock()
+	return nil
+}
+
+// permit creates permission for peer IP if it is not created yet.
+func (c *Client) permit(peer *net.UDPAddr) error {
+	c.mux.Lock()
+	_, ok := c.perms[peer.IP.String()]
+	c.mux.Unlock()
+	if ok {
+		return nil
+	}
+	return c.CreatePermission(peer)
+}
+
+// ErrNoChannels means that all channel numbers are used.
+var ErrNoChannels = errors.New("no free channel numbers")
+
+// Bind binds channel to peer, returning channel number. Data to peer will
+// be sent via ChannelData messages. Concurrent calls for the same peer
+// wait for single binding.
+//
+// RFC 5766 Section 11
+func (c *Client) Bind(addr net.Addr) (stun.ChannelNumber, error) {
+	peer, err := peerAddr(addr)
+	if err != nil {
+		return 0, err
+	}
+	key := peer.String()
+	c.mux.Lock()
+	if ch, ok := c.channels[key]; ok {
+		c.mux.Unlock()
+		return ch.number, nil
+	}
+	if p, ok := c.pending[key]; ok {
+		c.mux.Unlock()
+		<-p.done
+		if p.err != nil {
+			return 0, p.err
+		}
+		return p.ch.number, nil
+	}
+	number, ok := c.acquireNumber()
+	if !ok {
+		c.mux.Unlock()
+		return 0, ErrNoChannels
+	}
+	p := &pendingBind{
+		ch:   &channel{number: number, peer: peer},
+		done: make(chan struct{}),
+	}
+	c.pending[key] = p
+	c.mux.Unlock()
+	err = c.bind(p.ch)
+	c.mux.Lock()
+	delete(c.pending, key)
+	if err != nil {
+		c.free = append(c.free, number)
+	} else {
+		c.channels[key] = p.ch
+		c.numbers[number] = p.ch
+	}
+	p.err = err
+	close(p.done)
+	c.mux.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return number, nil
+}
+
+// acquireNumber returns unused channel number, preferring released ones.
+// Must be called with c.mux held.
+func (c *Client) acquireNumber() (stun.ChannelNumber, bool) {
+	if n := len(c.free); n > 0 {
+		number := c.free[n-1]
+		c.free = c.free[:n-1]
+		return number, true
+	}
+	if !c.nextNumber.Valid() {
+		return 0, false
+	}
+	number := c.nextNumber
+	c.nextNumber++
+	return number, true
+}
+
+// bind binds or refreshes channel. Channel binding also installs or
+// refreshes permission for peer IP.
+func (c *Client) bind(ch *channel) error {
+	if _, err := c.do(channelBindRequest, ch.number, stun.XORPeerAddress{IP: ch.peer.IP, Port: ch.peer.Port}); err != nil {
+		return err
+	}
+	now := c.clock.Now()
+	c.mux.Lock()
+	ch.refreshAt = now.Add(refreshAfter(ChannelBindingLifetime))
+	if refreshAt := now.Add(refreshAfter(PermissionLifetime)); refreshAt.After(c.perms[ch.peer.IP.String()]) {
+		c.perms[ch.peer.IP.String()] = refreshAt
+	}
+	c.mux.Unlock()
+	return nil
+}
+
+// collect refreshes allocation, permissions and channel bindings that are
+// about to expire. Errors are ignored, refresh is retried on next call.
+func (c *Client) collect(now time.Time) {
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+		return
+	}
+	var (
+		refresh  = !now.Before(c.refreshAt)
+		peers    []net.Addr
+		channels []*channel
+	)
+	for ip, refreshAt := range c.perms {
+		if !now.Before(refreshAt) {
+			peers = append(peers, &net.UDPAddr{IP: net.ParseIP(ip)})
+		}
+	}
+	for _, ch := range c.channels {
+		if !now.Before(ch.refreshAt) {
+			channels = append(channels, ch)
+		}
+	}
+	c.mux.Unlock()
+	if refresh {
+		_ = c.refresh()
+	}
+	// Channels are refreshed first, refreshing permissions too.
+	for _, ch := range channels {
+		_ = c.bind(ch)
+	}
+	if len(peers) > 0 {
+		_ = c.CreatePermission(peers...)
+	}
+}
+
+// WriteTo sends data to peer through relay. Permission for peer IP is
+// created if needed.
+func (c *Client) WriteTo(b []byte, addr net.Addr) (int, error) {
+	peer, err := peerAddr(addr)
+	if err != nil {
+		return 0, err
+	}
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+		return 0, ErrClientClosed
+	}
+	ch := c.channels[peer.String()]
+	c.mux.Unlock()
+	if ch != nil {
+		d := &stun.ChannelData{Number: ch.number, Data: b}
+		d.Encode()
+		if _, err = c.conn.Write(d.Raw); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if err = c.permit(peer); err != nil {
+		return 0, err
+	}
+	m, err := stun.Build(stun.TransactionID, sendIndication,
+		stun.XORPeerAddress{IP: peer.IP, Port: peer.Port}, stun.Data(b),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = c.conn.Write(m.Raw); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// push queues data from peer, dropping it if queue is full.
+func (c *Client) push(data []byte, addr *net.UDPAddr) {
+	p := packet{data: append([]byte(nil), data...), addr: addr}
+	select {
+	case c.data <- p:
+	default:
+	}
+}
+
+// handleData handles Data indication.
+//
+// RFC 5766 Section 10.4
+func (c *Client) handleData(m *stun.Message) {
+	var (
+		peer stun.XORPeerAddress
+		data stun.Data
+	)
+	if err := m.Parse(&peer, &data); err != nil {
+		return
+	}
+	c.push(data, &net.UDPAddr{IP: peer.IP, Port: peer.Port})
+}
+
+// handleChannelData handles ChannelData message.
+//
+// RFC 5766 Section 11.6
+func (c *Client) handleChannelData(b []byte) {
+	d := &stun.ChannelData{Raw: b}
+	if err := d.Decode(); err != nil {
+		return
+	}
+	c.mux.Lock()
+	ch, ok := c.numbers[d.Number]
+	c.mux.Unlock()
+	if !ok {
+		return
+	}
+	c.push(d.Data, ch.peer)
+}
+
+// timeoutErr is returned on read deadline.
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+// ReadFrom reads data received from peer through relay.
+func (c *Client) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		c.mux.Lock()
+		var (
+			deadline = c.deadline
+			changed  = c.deadlineChanged
+			timeout  <-chan time.Time
+		)
+		c.mux.Unlock()
+		select {
+		case <-c.done:
+			return 0, nil, ErrClientClosed
+		default:
+		}
+		var t *time.Timer
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, timeoutErr{}
+			}
+			t = time.NewTimer(d)
+			timeout = t.C
+		}
+		select {
+		case p := <-c.data:
+			stopTimer(t)
+			return copy(b, p.data), p.addr, nil
+		case <-c.done:
+			stopTimer(t)
+			return 0, nil, ErrClientClosed
+		case <-timeout:
+			return 0, nil, timeoutErr{}
+		case <-changed:
+			stopTimer(t)
+		}
+	}
+}
+
+func stopTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// SetReadDeadline sets deadline for ReadFrom calls.
+func (c *Client) SetReadDeadline(t time.Time) error {
+	c.mux.Lock()
+	c.deadline = t
+	close(c.deadlineChanged)
+	c.deadlineChanged = make(chan struct{})
+	c.mux.Unlock()
+	return nil
+}
+
+// SetWriteDeadline sets write deadline of underlying connection, if any.
+func (c *Client) SetWriteDeadline(t time.Time) error {
+	if c.packet == nil {
+		return nil
+	}
+	return c.packet.SetWriteDeadline(t)
+}
+
+// SetDeadline sets read and write deadlines.
+func (c *Client) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// ErrClientClosed means that client is already closed.
+var ErrClientClosed = errors.New("client is closed")
+
+// Close deletes allocation and stops client, conn is not closed.
+//
+// RFC 5766 Section 7
+func (c *Client) Close() error {
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+		return ErrClientClosed
+	}
+	c.closed = true
+	c.mux.Unlock()
+	err := c.collector.Close()
+	if _, refreshErr := c.do(refreshRequest, stun.Lifetime{}); err == nil {
+		err = refreshErr
+	}
+	close(c.done)
+	return c.closeSTUN(err)
+}
+
+// serverConn adapts net.PacketConn to stun.Connection for server address,
+// demultiplexing Data indications and ChannelData messages.
+//
+// Close does not close underlying connection but unblocks pending read.
+type serverConn struct {
+	conn   net.PacketConn
+	server net.Addr
+	client *Client
+	buf    []byte        // accessed only by reading goroutine
+	m      *stun.Message // accessed only by reading goroutine
+}
+
+func (s *serverConn) Read(b []byte) (int, error) {
+	for {
+		n, addr, err := s.conn.ReadFrom(s.buf)
+		if err != nil {
+			return 0, err
+		}
+		if addr.String() != s.server.String() {
+			continue
+		}
+		data := s.buf[:n]
+		if stun.IsChannelData(data) {
+			s.client.handleChannelData(data)
+			continue
+		}
+		if !stun.IsMessage(data) {
+			continue
+		}
+		s.m.Raw = append(s.m.Raw[:0], data...)
+		if err = s.m.Decode(); err != nil {
+			continue
+		}
+		if s.m.Type == dataIndication {
+			s.client.handleData(s.m)
+			continue
+		}
+		return copy(b, data), nil
+	}
+}
+
+func (s *serverConn) Write(b []byte) (int, error) {
+	return s.conn.WriteTo(b, s.server)
+}
+
+func (s *serverConn) Close() error {
+	// Forcing pending ReadFrom to return.
+	return s.conn.SetReadDeadline(time.Unix(1, 0))
+}
@@ -0,0 +1,547 @@
+package turn
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+const (
+	testUsername = "user"
+	testPassword = "secret"
+	testRealm    = "realm"
+)
+
+// testServer is stand-in TURN server that serves single allocation.
+type testServer struct {
+	t         *testing.T
+	conn      net.PacketConn
+	integrity stun.MessageIntegrity
+	wg        sync.WaitGroup
+
+	mux      sync.Mutex
+	nonce    stun.Nonce
+	client   net.Addr
+	relay    net.PacketConn
+	lifetime time.Duration
+	perms    map[string]bool
+	channels map[stun.ChannelNumber]*net.UDPAddr
+	requests map[stun.Method]int
+	// rejectBind makes server reject channel bindings.
+	rejectBind bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		t:         t,
+		conn:      conn,
+		integrity: stun.NewLongTermIntegrity(testUsername, testRealm, testPassword),
+		nonce:     stun.NewNonce("nonce"),
+		perms:     make(map[string]bool),
+		channels:  make(map[stun.ChannelNumber]*net.UDPAddr),
+		requests:  make(map[stun.Method]int),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *testServer) count(method stun.Method) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.requests[method]
+}
+
+func (s *testServer) setNonce(nonce string) {
+	s.mux.Lock()
+	s.nonce = stun.NewNonce(nonce)
+	s.mux.Unlock()
+}
+
+func (s *testServer) setRejectBind(reject bool) {
+	s.mux.Lock()
+	s.rejectBind = reject
+	s.mux.Unlock()
+}
+
+func (s *testServer) Close() {
+	_ = s.conn.Close()
+	s.mux.Lock()
+	if s.relay != nil {
+		_ = s.relay.Close()
+	}
+	s.mux.Unlock()
+	s.wg.Wait()
+}
+
+func (s *testServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if stun.IsChannelData(buf[:n]) {
+			s.handleChannelData(buf[:n])
+			continue
+		}
+		m := new(stun.Message)
+		if _, err = m.Write(buf[:n]); err != nil {
+			s.t.Error(err)
+			continue
+		}
+		if m.Type.Class == stun.ClassIndication {
+			s.handleSend(m)
+			continue
+		}
+		res := s.handle(m, addr)
+		if _, err = s.conn.WriteTo(res.Raw, addr); err != nil {
+			s.t.Error(err)
+		}
+	}
+}
+
+func (s *testServer) handle(m *stun.Message, addr net.Addr) *stun.Message {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.requests[m.Type.Method]++
+	errType := stun.NewType(m.Type.Method, stun.ClassErrorResponse)
+	var nonce stun.Nonce
+	if !m.Contains(stun.AttrMessageIntegrity) {
+		return stun.MustBuild(m, errType, stun.CodeUnauthorized, stun.NewRealm(testRealm), s.nonce)
+	}
+	if err := s.integrity.Check(m); err != nil {
+		return stun.MustBuild(m, errType, stun.CodeUnauthorized, stun.NewRealm(testRealm), s.nonce)
+	}
+	if err := nonce.GetFrom(m); err != nil || !bytes.Equal(nonce, s.nonce) {
+		return stun.MustBuild(m, errType, stun.CodeStaleNonce, s.nonce)
+	}
+	var (
+		lifetime stun.Lifetime
+		setters  = []stun.Setter{m, stun.NewType(m.Type.Method, stun.ClassSuccessResponse)}
+		peer     stun.XORPeerAddress
+	)
+	switch m.Type.Method {
+	case stun.MethodAllocate:
+		relay, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		s.client = addr
+		s.relay = relay
+		s.lifetime = time.Minute * 10
+		if lifetime.GetFrom(m) == nil {
+			s.lifetime = lifetime.Duration
+		}
+		relayed := relay.LocalAddr().(*net.UDPAddr)
+		mapped := addr.(*net.UDPAddr)
+		setters = append(setters,
+			stun.XORRelayedAddress{IP: relayed.IP, Port: relayed.Port},
+			stun.XORMappedAddress{IP: mapped.IP, Port: mapped.Port},
+			stun.Lifetime{Duration: s.lifetime},
+		)
+		s.wg.Add(1)
+		go s.serveRelay(relay)
+	case stun.MethodRefresh:
+		if lifetime.GetFrom(m) == nil {
+			s.lifetime = lifetime.Duration
+		}
+		if s.lifetime == 0 {
+			_ = s.relay.Close()
+		}
+		setters = append(setters, stun.Lifetime{Duration: s.lifetime})
+	case stun.MethodCreatePermission:
+		for _, a := range m.Attributes {
+			if a.Type != stun.AttrXORPeerAddress {
+				continue
+			}
+			v := &stun.Message{TransactionID: m.TransactionID}
+			v.Add(a.Type, a.Value)
+			if err := peer.GetFrom(v); err != nil {
+				s.t.Error(err)
+			}
+			s.perms[peer.IP.String()] = true
+		}
+	case stun.MethodChannelBind:
+		var number stun.ChannelNumber
+		if err := m.Parse(&number, &peer); err != nil {
+			s.t.Error(err)
+		}
+		var (
+			addr   = &net.UDPAddr{IP: peer.IP, Port: peer.Port}
+			reject = s.rejectBind
+		)
+		for n, p := range s.channels {
+			// Peer can't be bound to different channel.
+			reject = reject || n != number && p.String() == addr.String()
+		}
+		if reject {
+			return stun.MustBuild(m, errType, stun.CodeBadRequest, s.integrity, stun.Fingerprint)
+		}
+		s.channels[number] = addr
+		s.perms[peer.IP.String()] = true
+	}
+	return stun.MustBuild(append(setters, s.integrity, stun.Fingerprint)...)
+}
+
+func (s *testServer) handleSend(m *stun.Message) {
+	var (
+		peer stun.XORPeerAddress
+		data stun.Data
+	)
+	if err := m.Parse(&peer, &data); err != nil {
+		s.t.Error(err)
+		return
+	}
+	s.mux.Lock()
+	permitted := s.perms[peer.IP.String()]
+	relay := s.relay
+	s.mux.Unlock()
+	if !permitted {
+		s.t.Error("no permission for", peer)
+		return
+	}
+	_, _ = relay.WriteTo(data, &net.UDPAddr{IP: peer.IP, Port: peer.Port})
+}
+
+func (s *testServer) handleChannelData(b []byte) {
+	d := &stun.ChannelData{Raw: b}
+	if err := d.Decode(); err != nil {
+		s.t.Error(err)
+		return
+	}
+	s.mux.Lock()
+	peer := s.channels[d.Number]
+	relay := s.relay
+	s.mux.Unlock()
+	if peer == nil {
+		s.t.Error("channel is not bound:", d.Number)
+		return
+	}
+	_, _ = relay.WriteTo(d.Data, peer)
+}
+
+func (s *testServer) serveRelay(relay net.PacketConn) {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := relay.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		peer := addr.(*net.UDPAddr)
+		s.mux.Lock()
+		var (
+			number stun.ChannelNumber
+			client = s.client
+		)
+		for num, p := range s.channels {
+			if p.String() == peer.String() {
+				number = num
+			}
+		}
+		permitted := s.perms[peer.IP.String()]
+		s.mux.Unlock()
+		var raw []byte
+		switch {
+		case number != 0:
+			d := &stun.ChannelData{Number: number, Data: buf[:n]}
+			d.Encode()
+			raw = d.Raw
+		case permitted:
+			raw = stun.MustBuild(stun.TransactionID, dataIndication,
+				stun.XORPeerAddress{IP: peer.IP, Port: peer.Port}, stun.Data(buf[:n]),
+			).Raw
+		default:
+			continue
+		}
+		_, _ = s.conn.WriteTo(raw, client)
+	}
+}
+
+type manualClock struct {
+	mux     sync.Mutex
+	current time.Time
+}
+
+func (m *manualClock) Add(d time.Duration) time.Time {
+	m.mux.Lock()
+	v := m.current.Add(d)
+	m.current = v
+	m.mux.Unlock()
+	return v
+}
+
+func (m *manualClock) Now() time.Time {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.current
+}
+
+type manualCollector struct {
+	f func(now time.Time)
+}
+
+func (m *manualCollector) Start(rate time.Duration, f func(now time.Time)) error {
+	m.f = f
+	return nil
+}
+
+func (m *manualCollector) Close() error { return nil }
+
+func listenUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readFrom(t *testing.T, conn net.PacketConn) ([]byte, net.Addr) {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1500)
+	n, addr, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n], addr
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	var (
+		clock     = &manualClock{current: time.Now()}
+		collector = new(manualCollector)
+	)
+	c, err := Allocate(conn, s.conn.LocalAddr(),
+		WithCredentials(testUsername, testPassword),
+		WithLifetime(time.Minute*5),
+		WithClock(clock), WithCollector(collector),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Relayed().Port == 0 || c.LocalAddr().String() != c.Relayed().String() {
+		t.Errorf("bad relayed address %s", c.Relayed())
+	}
+	if c.Mapped().String() != conn.LocalAddr().String() {
+		t.Errorf("bad mapped address %s", c.Mapped())
+	}
+	peer := listenUDP(t)
+	defer peer.Close()
+	t.Run("Send", func(t *testing.T) {
+		if _, err := c.WriteTo([]byte("hello"), peer.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		data, from := readFrom(t, peer)
+		if string(data) != "hello" || from.String() != c.Relayed().String() {
+			t.Errorf("unexpected %q from %s", data, from)
+		}
+		if s.count(stun.MethodCreatePermission) != 1 {
+			t.Error("permission should be created")
+		}
+		// Permission is already installed.
+		if _, err := c.WriteTo([]byte("hello"), peer.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		readFrom(t, peer)
+		if s.count(stun.MethodCreatePermission) != 1 {
+			t.Error("permission should be created once")
+		}
+	})
+	t.Run("Data", func(t *testing.T) {
+		if _, err := peer.WriteTo([]byte("world"), c.Relayed()); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 100)
+		n, from, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != "world" || from.String() != peer.LocalAddr().String() {
+			t.Errorf("unexpected %q from %s", buf[:n], from)
+		}
+	})
+	t.Run("Channel", func(t *testing.T) {
+		number, err := c.Bind(peer.LocalAddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if number != stun.MinChannelNumber {
+			t.Errorf("unexpected channel number %s", number)
+		}
+		if again, err := c.Bind(peer.LocalAddr()); err != nil || again != number {
+			t.Error("should return bound channel")
+		}
+		if _, err = c.WriteTo([]byte("channel"), peer.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := readFrom(t, peer)
+		if string(data) != "channel" {
+			t.Errorf("unexpected %q", data)
+		}
+		if _, err = peer.WriteTo([]byte("back"), c.Relayed()); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 100)
+		n, from, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != "back" || from.String() != peer.LocalAddr().String() {
+			t.Errorf("unexpected %q from %s", buf[:n], from)
+		}
+	})
+	t.Run("Refresh", func(t *testing.T) {
+		collector.f(clock.Add(time.Minute))
+		if s.count(stun.MethodRefresh) != 0 || s.count(stun.MethodCreatePermission) != 1 {
+			t.Fatal("nothing should be refreshed")
+		}
+		// Allocation and permission are refreshed 1 minute before
+		// expiration.
+		collector.f(clock.Add(time.Minute * 3))
+		if s.count(stun.MethodRefresh) != 1 {
+			t.Error("allocation should be refreshed")
+		}
+		if s.count(stun.MethodCreatePermission) != 2 {
+			t.Error("permission should be refreshed")
+		}
+		if s.count(stun.MethodChannelBind) != 1 {
+			t.Error("channel should not be refreshed")
+		}
+		collector.f(clock.Add(time.Minute * 5))
+		if s.count(stun.MethodChannelBind) != 2 {
+			t.Error("channel should be refreshed")
+		}
+		if s.count(stun.MethodRefresh) != 2 {
+			t.Error("allocation should be refreshed again")
+		}
+	})
+	t.Run("StaleNonce", func(t *testing.T) {
+		s.setNonce("other")
+		if err := c.CreatePermission(peer.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("ReadDeadline", func(t *testing.T) {
+		if err := c.SetReadDeadline(time.Now().Add(time.Millisecond * 10)); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := c.ReadFrom(make([]byte, 100))
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	if err = c.Close(); err != nil {
+		t.Error(err)
+	}
+	if s.count(stun.MethodRefresh) != 3 {
+		t.Error("allocation should be deleted")
+	}
+	if err = c.Close(); err != ErrClientClosed {
+		t.Error("second close should fail")
+	}
+	if _, _, err = c.ReadFrom(make([]byte, 100)); err != ErrClientClosed {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err = c.WriteTo([]byte{1}, peer.LocalAddr()); err != ErrClientClosed {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestClient_Bind(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	c, err := Allocate(conn, s.conn.LocalAddr(), WithCredentials(testUsername, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	peer := listenUDP(t)
+	defer peer.Close()
+	t.Run("Failed", func(t *testing.T) {
+		s.setRejectBind(true)
+		defer s.setRejectBind(false)
+		if _, err := c.Bind(peer.LocalAddr()); err == nil {
+			t.Error("should fail")
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		const callers = 8
+		var (
+			wg      sync.WaitGroup
+			start   = make(chan struct{})
+			numbers = make(chan stun.ChannelNumber, callers)
+		)
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				number, err := c.Bind(peer.LocalAddr())
+				if err != nil {
+					t.Error(err)
+				}
+				numbers <- number
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(numbers)
+		for number := range numbers {
+			// Number of failed binding is reused.
+			if number != stun.MinChannelNumber {
+				t.Errorf("unexpected channel number %s", number)
+			}
+		}
+		if s.count(stun.MethodChannelBind) != 2 {
+			t.Error("channel should be bound once after failure")
+		}
+	})
+}
+
+func TestAllocate_Unauthorized(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	for _, options := range [][]ClientOption{
+		nil,
+		{WithCredentials(testUsername, "bad")},
+	} {
+		_, err := Allocate(conn, s.conn.LocalAddr(), options...)
+		if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != stun.CodeUnauthorized {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	// Connection can be reused after failure.
+	c, err := Allocate(conn, s.conn.LocalAddr(), WithCredentials(testUsername, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,174 @@
+package turn
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+// listenE2E starts in-process TURN server on UDP and TCP loopback
+// addresses.
+func listenE2E(t *testing.T) (s *Server, udpAddr, tcpAddr net.Addr) {
+	t.Helper()
+	s, err := NewServer(
+		WithRealm(testRealm), WithUser(testUsername, testPassword),
+		WithSoftware("gortc/e2e"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := listenUDP(t)
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = s.Serve(conn)
+	}()
+	go func() {
+		_ = s.ServeTCP(l)
+	}()
+	return s, conn.LocalAddr(), l.Addr()
+}
+
+// testE2EBinding checks authenticated Binding transaction with server over
+// stun.Client.
+func testE2EBinding(t *testing.T, addr net.Addr) {
+	var (
+		nonce stun.Nonce
+		realm stun.Realm
+	)
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var options []stun.ClientOption
+	if addr.Network() == "tcp" {
+		options = append(options, stun.WithNoRetransmit)
+	}
+	client, err := stun.NewClient(conn, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// First request should error.
+	request := stun.MustBuild(stun.BindingRequest, stun.TransactionID, stun.Fingerprint)
+	if err = client.Do(request, func(event stun.Event) {
+		if event.Error != nil {
+			t.Error("got event with error:", event.Error)
+			return
+		}
+		response := event.Message
+		if response.Type != stun.BindingError {
+			t.Error("bad message", response)
+			return
+		}
+		var errCode stun.ErrorCodeAttribute
+		if codeErr := errCode.GetFrom(response); codeErr != nil || errCode.Code != stun.CodeUnauthorized {
+			t.Error("unexpected error code:", errCode, codeErr)
+		}
+		// Values are copied, response buffer is reused by client.
+		var (
+			n stun.Nonce
+			r stun.Realm
+		)
+		if parseErr := response.Parse(&n, &r); parseErr != nil {
+			t.Error("failed to parse:", parseErr)
+		}
+		nonce = append(nonce, n...)
+		realm = append(realm, r...)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Authenticating and sending second request.
+	request = stun.MustBuild(stun.TransactionID, stun.BindingRequest,
+		stun.NewUsername(testUsername), nonce, realm,
+		stun.NewLongTermIntegrity(testUsername, realm.String(), testPassword),
+		stun.Fingerprint,
+	)
+	if err = client.Do(request, func(event stun.Event) {
+		if event.Error != nil {
+			t.Error("got event with error:", event.Error)
+			return
+		}
+		response := event.Message
+		if response.Type != stun.BindingSuccess {
+			t.Error("bad message", response)
+			return
+		}
+		var xorMapped stun.XORMappedAddress
+		if parseErr := response.Parse(&xorMapped); parseErr != nil {
+			t.Error("failed to parse xor mapped address:", parseErr)
+			return
+		}
+		if conn.LocalAddr().String() != xorMapped.String() {
+			t.Error(conn.LocalAddr(), "!=", xorMapped)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testE2ERelay allocates relay and checks that data is relayed to peer and
+// back, with and without channel binding.
+func testE2ERelay(t *testing.T, addr net.Addr) {
+	conn := listenUDP(t)
+	defer conn.Close()
+	client, err := Allocate(conn, addr, WithCredentials(testUsername, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	peer := listenUDP(t)
+	defer peer.Close()
+	buf := make([]byte, 1500)
+	for _, bind := range []bool{false, true} {
+		if bind {
+			if _, err = client.Bind(peer.LocalAddr()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data := []byte("hello")
+		if _, err = client.WriteTo(data, peer.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		got, from := readFrom(t, peer)
+		if !bytes.Equal(got, data) || from.String() != client.Relayed().String() {
+			t.Fatal("unexpected data", got, "from", from)
+		}
+		if _, err = peer.WriteTo(data, from); err != nil {
+			t.Fatal(err)
+		}
+		if err = client.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+		n, from, err := client.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], data) || from.String() != peer.LocalAddr().String() {
+			t.Fatal("unexpected data", buf[:n], "from", from)
+		}
+	}
+}
+
+// TestE2E runs stun.Client and Client against in-process Server over
+// loopback.
+func TestE2E(t *testing.T) {
+	s, udpAddr, tcpAddr := listenE2E(t)
+	t.Run("UDP", func(t *testing.T) {
+		testE2EBinding(t, udpAddr)
+	})
+	t.Run("TCP", func(t *testing.T) {
+		testE2EBinding(t, tcpAddr)
+	})
+	t.Run("Relay", func(t *testing.T) {
+		testE2ERelay(t, udpAddr)
+	})
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,1004 @@
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" // #nosec
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"gortc.io/stun"
+	"gortc.io/stun/internal/clockutil"
+)
+
+// Default and maximum lifetimes of allocation.
+//
+// RFC 5766 Section 2.2
+const (
+	DefaultLifetime = time.Minute * 10
+	MaxLifetime     = time.Hour
+)
+
+// nonceLifetime is duration after which nonce is stale.
+const nonceLifetime = time.Minute * 10
+
+// defaultRealm is realm of server if not set by WithRealm.
+const defaultRealm = "gortc.io"
+
+// maxPacketSize is size of read buffers, enough for any UDP datagram.
+const maxPacketSize = 0xFFFF
+
+// AuthHandler returns long-term credential key of user in realm or false
+// if user is unknown, addr is address of client. The key can be created
+// via stun.NewLongTermIntegrity.
+type AuthHandler func(username, realm string, addr net.Addr) (stun.MessageIntegrity, bool)
+
+// TokenHandler returns codec of access tokens with key id kid or false if
+// key is unknown.
+type TokenHandler func(kid string) (*stun.TokenCodec, bool)
+
+// OriginHandler reports whether requests with ORIGIN attribute values
+// origins are allowed from client with address addr, origins are empty if
+// request has no ORIGIN. Requests that are not allowed are rejected with
+// 403 Forbidden.
+type OriginHandler func(origins []string, addr net.Addr) bool
+
+// ServerOption sets some server option.
+type ServerOption func(s *Server)
+
+// WithRealm sets realm of long-term credentials.
+func WithRealm(realm string) ServerOption {
+	return func(s *Server) {
+		s.realm = stun.NewRealm(realm)
+	}
+}
+
+// WithUser adds user with long-term credentials. Users are ignored if
+// AuthHandler is set.
+func WithUser(username, password string) ServerOption {
+	return func(s *Server) {
+		s.users[username] = password
+	}
+}
+
+// WithAuthHandler sets handler that provides long-term credentials.
+func WithAuthHandler(h AuthHandler) ServerOption {
+	return func(s *Server) {
+		s.auth = h
+	}
+}
+
+// WithThirdPartyAuthorization enables third-party authorization, where
+// requests with ACCESS-TOKEN are authenticated by mac_key of token that is
+// decrypted by codec from h, and USERNAME is key id. Server name is sent
+// in THIRD-PARTY-AUTHORIZATION of 401 responses. Requests without token
+// are authenticated with long-term credentials.
+//
+// RFC 7635
+func WithThirdPartyAuthorization(server string, h TokenHandler) ServerOption {
+	return func(s *Server) {
+		s.thirdParty = stun.NewThirdPartyAuthorization(server)
+		s.tokens = h
+	}
+}
+
+// WithOriginHandler sets handler that allows or denies requests by ORIGIN
+// attribute, which is sent by browsers. All requests are allowed by
+// default.
+func WithOriginHandler(h OriginHandler) ServerOption {
+	return func(s *Server) {
+		s.origin = h
+	}
+}
+
+// WithEphemeralCredentials sets AuthHandler that authenticates users by
+// time-limited credentials of "TURN REST API", derived from secret shared
+// with web service.
+func WithEphemeralCredentials(c *stun.EphemeralCredentials) ServerOption {
+	return func(s *Server) {
+		s.auth = func(username, realm string, addr net.Addr) (stun.MessageIntegrity, bool) {
+			if realm != s.realm.String() {
+				return nil, false
+			}
+			integrity, err := c.Integrity(username, realm)
+			return integrity, err == nil
+		}
+	}
+}
+
+// WithSoftware sets SOFTWARE attribute value for responses.
+func WithSoftware(software string) ServerOption {
+	return func(s *Server) {
+		s.software = stun.NewSoftware(software)
+	}
+}
+
+// WithRelayIP sets IP address of relayed transport addresses. If not set,
+// local IP address of connection to client is used, so it must be set if
+// server listens on unspecified address.
+func WithRelayIP(ip net.IP) ServerOption {
+	return func(s *Server) {
+		s.relayIP = ip
+	}
+}
+
+// WithQuota limits number of allocations per user, 486 Allocation Quota
+// Reached is returned if limit is reached. Zero means no limit.
+func WithQuota(n int) ServerOption {
+	return func(s *Server) {
+		s.quota = n
+	}
+}
+
+// WithCapacity limits total number of allocations, 508 Insufficient
+// Capacity is returned if limit is reached. Zero means no limit.
+func WithCapacity(n int) ServerOption {
+	return func(s *Server) {
+		s.capacity = n
+	}
+}
+
+// WithServerClock sets Clock of server, the source of current time for
+// expiration of allocations, permissions, channel bindings and nonces.
+func WithServerClock(clock stun.Clock) ServerOption {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithServerCollector sets collector that removes expired allocations,
+// permissions and channel bindings, which is ticker with
+// DefaultRefreshRate by default.
+func WithServerCollector(coll stun.Collector) ServerOption {
+	return func(s *Server) {
+		s.collector = coll
+	}
+}
+
+// fiveTuple identifies allocation by client and server transport
+// addresses, protocol is network of client address.
+//
+// RFC 5766 Section 2.2
+type fiveTuple struct {
+	network string
+	client  string
+	server  string
+}
+
+// transport is connection to client, which is packet connection with
+// client address or stream connection.
+type transport struct {
+	packet net.PacketConn
+	stream net.Conn
+	addr   net.Addr
+}
+
+func (t transport) local() net.Addr {
+	if t.stream != nil {
+		return t.stream.LocalAddr()
+	}
+	return t.packet.LocalAddr()
+}
+
+func (t transport) tuple() fiveTuple {
+	return fiveTuple{
+		network: t.addr.Network(),
+		client:  t.addr.String(),
+		server:  t.local().String(),
+	}
+}
+
+func (t transport) write(b []byte) error {
+	if t.stream != nil {
+		_, err := t.stream.Write(b)
+		return err
+	}
+	_, err := t.packet.WriteTo(b, t.addr)
+	return err
+}
+
+// addrIPPort returns IP and port of UDP or TCP address.
+func addrIPPort(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	default:
+		return nil, 0
+	}
+}
+
+// binding is channel bound to peer.
+type binding struct {
+	number  stun.ChannelNumber
+	peer    *net.UDPAddr
+	expires time.Time
+}
+
+// allocation is relayed transport address allocated for client.
+//
+// RFC 5766 Section 5
+type allocation struct {
+	tuple    fiveTuple
+	client   transport
+	username string
+	tid      [stun.TransactionIDSize]byte // of Allocate request
+	relay    net.PacketConn
+	lifetime time.Duration
+	expires  time.Time
+	perms    map[string]time.Time // expiration by peer IP
+	channels map[stun.ChannelNumber]*binding
+	peers    map[string]*binding // by peer address
+}
+
+func (a *allocation) permitted(ip net.IP, now time.Time) bool {
+	expires, ok := a.perms[ip.String()]
+	return ok && now.Before(expires)
+}
+
+// Server is TURN server that relays UDP data for clients that are
+// connected over UDP or TCP, authenticating all requests, including
+// Binding, with long-term credentials.
+//
+// EVEN-PORT, RESERVATION-TOKEN and DONT-FRAGMENT attributes are not
+// supported and are treated as unknown.
+type Server struct {
+	realm      stun.Realm
+	software   stun.Software
+	users      map[string]string
+	auth       AuthHandler
+	thirdParty stun.ThirdPartyAuthorization
+	tokens     TokenHandler
+	origin     OriginHandler
+	relayIP    net.IP
+	quota      int
+	capacity   int
+	clock      stun.Clock
+	collector  stun.Collector
+	secret     []byte // of nonces
+	wg         sync.WaitGroup
+
+	mux         sync.Mutex
+	closed      bool
+	closers     map[io.Closer]struct{} // served connections and listeners
+	allocations map[fiveTuple]*allocation
+	allocated   map[string]int // number of allocations by username
+}
+
+// nonceSecretSize is size of random secret that authenticates nonces.
+const nonceSecretSize = 16
+
+// NewServer initializes and returns new Server. Use Serve and ServeTCP to
+// serve clients and Close to stop server.
+func NewServer(options ...ServerOption) (*Server, error) {
+	s := &Server{
+		realm:       stun.NewRealm(defaultRealm),
+		users:       make(map[string]string),
+		clock:       clockutil.System{},
+		secret:      make([]byte, nonceSecretSize),
+		closers:     make(map[io.Closer]struct{}),
+		allocations: make(map[fiveTuple]*allocation),
+		allocated:   make(map[string]int),
+	}
+	for _, o := range options {
+		o(s)
+	}
+	if _, err := rand.Read(s.secret); err != nil {
+		return nil, err
+	}
+	if s.auth == nil {
+		s.auth = s.authUser
+	}
+	if s.collector == nil {
+		s.collector = clockutil.NewCollector(s.clock)
+	}
+	if err := s.collector.Start(DefaultRefreshRate, s.collect); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// authUser is default AuthHandler that looks up users added by WithUser.
+func (s *Server) authUser(username, realm string, addr net.Addr) (stun.MessageIntegrity, bool) {
+	password, ok := s.users[username]
+	if !ok || realm != s.realm.String() {
+		return nil, false
+	}
+	return stun.NewLongTermIntegrity(username, realm, password), true
+}
+
+// ErrServerClosed means that server is already closed.
+var ErrServerClosed = errors.New("server is closed")
+
+func (s *Server) track(c io.Closer) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	s.closers[c] = struct{}{}
+	return nil
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mux.Lock()
+	delete(s.closers, c)
+	s.mux.Unlock()
+}
+
+// serveErr returns ErrServerClosed instead of err if server is closed.
+func (s *Server) serveErr(err error) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	return err
+}
+
+// Serve serves clients on conn until conn or server is closed, returning
+// ErrServerClosed in latter case. Conn is closed on Close.
+func (s *Server) Serve(conn net.PacketConn) error {
+	if err := s.track(conn); err != nil {
+		return err
+	}
+	defer s.untrack(conn)
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return s.serveErr(err)
+		}
+		s.handle(transport{packet: conn, addr: addr}, buf[:n])
+	}
+}
+
+// ServeTCP accepts connections of clients on l and serves them until l
+// or server is closed, returning ErrServerClosed in latter case. Listener
+// and accepted connections are closed on Close.
+//
+// Allocation is deleted when connection is closed.
+//
+// RFC 5766 Section 2.1
+func (s *Server) ServeTCP(l net.Listener) error {
+	if err := s.track(l); err != nil {
+		return err
+	}
+	defer s.untrack(l)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return s.serveErr(err)
+		}
+		if err = s.track(conn); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		s.wg.Add(1)
+		go s.serveStream(conn)
+	}
+}
+
+func (s *Server) serveStream(conn net.Conn) {
+	defer s.wg.Done()
+	t := transport{stream: conn, addr: conn.RemoteAddr()}
+	defer func() {
+		s.untrack(conn)
+		_ = conn.Close()
+		s.deallocate(t.tuple())
+	}()
+	r := stun.NewStreamReader(conn)
+	for {
+		frame, err := r.Next()
+		if err != nil {
+			return
+		}
+		s.handle(t, frame)
+	}
+}
+
+// Close stops server, closing all served connections and listeners and
+// deleting all allocations.
+func (s *Server) Close() error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return ErrServerClosed
+	}
+	s.closed = true
+	closers := s.closers
+	s.closers = nil
+	for tuple, a := range s.allocations {
+		s.deleteAllocation(tuple, a)
+	}
+	s.mux.Unlock()
+	err := s.collector.Close()
+	for c := range closers {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	s.wg.Wait()
+	return err
+}
+
+// collect removes expired allocations, permissions and channel bindings.
+func (s *Server) collect(now time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for tuple, a := range s.allocations {
+		if !now.Before(a.expires) {
+			s.deleteAllocation(tuple, a)
+			continue
+		}
+		for ip, expires := range a.perms {
+			if !now.Before(expires) {
+				delete(a.perms, ip)
+			}
+		}
+		for number, b := range a.channels {
+			if !now.Before(b.expires) {
+				delete(a.channels, number)
+				delete(a.peers, b.peer.String())
+			}
+		}
+	}
+}
+
+// deleteAllocation deletes allocation, s.mux must be locked.
+func (s *Server) deleteAllocation(tuple fiveTuple, a *allocation) {
+	delete(s.allocations, tuple)
+	if s.allocated[a.username]--; s.allocated[a.username] <= 0 {
+		delete(s.allocated, a.username)
+	}
+	_ = a.relay.Close()
+}
+
+func (s *Server) deallocate(tuple fiveTuple) {
+	s.mux.Lock()
+	if a, ok := s.allocations[tuple]; ok {
+		s.deleteAllocation(tuple, a)
+	}
+	s.mux.Unlock()
+}
+
+// handle handles STUN message or ChannelData message from client.
+func (s *Server) handle(t transport, b []byte) {
+	if stun.IsChannelData(b) {
+		s.handleChannelData(t, b)
+		return
+	}
+	if !stun.IsMessage(b) {
+		return
+	}
+	req := &stun.Message{Raw: append([]byte(nil), b...)}
+	if err := req.Decode(); err != nil {
+		return
+	}
+	switch req.Type.Class {
+	case stun.ClassIndication:
+		if req.Type.Method == stun.MethodSend {
+			s.handleSend(t, req)
+		}
+	case stun.ClassRequest:
+		res := new(stun.Message)
+		if err := s.process(t, req, res); err != nil {
+			return
+		}
+		// Errors are ignored, the client will retransmit the request.
+		_ = t.write(res.Raw)
+	}
+}
+
+// nonce returns new nonce that contains its expiration time and HMAC, so
+// nonces are validated without storing them.
+func (s *Server) nonce() stun.Nonce {
+	b := make([]byte, 8, 8+sha1.Size)
+	binary.BigEndian.PutUint64(b, uint64(s.clock.Now().Add(nonceLifetime).Unix()))
+	mac := hmac.New(sha1.New, s.secret)
+	_, _ = mac.Write(b)
+	return stun.Nonce(hex.EncodeToString(mac.Sum(b)))
+}
+
+func (s *Server) validNonce(nonce stun.Nonce) bool {
+	b, err := hex.DecodeString(string(nonce))
+	if err != nil || len(b) != 8+sha1.Size {
+		return false
+	}
+	mac := hmac.New(sha1.New, s.secret)
+	_, _ = mac.Write(b[:8])
+	if !hmac.Equal(mac.Sum(nil), b[8:]) {
+		return false
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b[:8])), 0)
+	return s.clock.Now().Before(expires)
+}
+
+// authenticate checks long-term credentials of request, returning
+// integrity key and username or error code.
+//
+// RFC 5389 Section 10.2.2
+func (s *Server) authenticate(t transport, req *stun.Message) (stun.MessageIntegrity, string, stun.ErrorCode) {
+	if !req.Contains(stun.AttrMessageIntegrity) {
+		return nil, "", stun.CodeUnauthorized
+	}
+	var (
+		username stun.Username
+		realm    stun.Realm
+		nonce    stun.Nonce
+	)
+	if err := req.Parse(&username, &realm, &nonce); err != nil {
+		return nil, "", stun.CodeBadRequest
+	}
+	if !s.validNonce(nonce) {
+		return nil, "", stun.CodeStaleNonce
+	}
+	var (
+		integrity stun.MessageIntegrity
+		ok        bool
+	)
+	if s.tokens != nil && req.Contains(stun.AttrAccessToken) {
+		integrity, ok = s.tokenIntegrity(username.String(), req)
+	} else {
+		integrity, ok = s.auth(username.String(), realm.String(), t.addr)
+	}
+	if !ok {
+		return nil, "", stun.CodeUnauthorized
+	}
+	if err := integrity.Check(req); err != nil {
+		return nil, "", stun.CodeUnauthorized
+	}
+	return integrity, username.String(), 0
+}
+
+// allowedOrigin reports whether ORIGIN attributes of request are allowed
+// by OriginHandler.
+func (s *Server) allowedOrigin(t transport, req *stun.Message) bool {
+	if s.origin == nil {
+		return true
+	}
+	var origins stun.Origins
+	if err := origins.GetFrom(req); err != nil && err != stun.ErrAttributeNotFound {
+		return false
+	}
+	values := make([]string, len(origins))
+	for i := range origins {
+		values[i] = origins[i].String()
+	}
+	return s.origin(values, t.addr)
+}
+
+// tokenIntegrity returns integrity keyed by mac_key of ACCESS-TOKEN of
+// request or false if token is invalid or expired.
+//
+// RFC 7635 Section 4.1
+func (s *Server) tokenIntegrity(kid string, req *stun.Message) (stun.MessageIntegrity, bool) {
+	codec, ok := s.tokens(kid)
+	if !ok {
+		return nil, false
+	}
+	var accessToken stun.AccessToken
+	if err := accessToken.GetFrom(req); err != nil {
+		return nil, false
+	}
+	token, err := codec.Decrypt(accessToken)
+	if err != nil || token.Expired(s.clock.Now()) {
+		return nil, false
+	}
+	return token.Integrity(), true
+}
+
+// unknownAttributes returns comprehension-required attributes of m that
+// are not supported by server.
+func (s *Server) unknownAttributes(m *stun.Message) stun.UnknownAttributes {
+	var unknown stun.UnknownAttributes
+	for _, a := range m.Attributes {
+		if a.Type.Optional() {
+			continue
+		}
+		if a.Type == stun.AttrAccessToken && s.tokens != nil {
+			continue
+		}
+		switch a.Type {
+		case stun.AttrUsername, stun.AttrRealm, stun.AttrNonce, stun.AttrMessageIntegrity,
+			stun.AttrLifetime, stun.AttrRequestedTransport, stun.AttrRequestedAddressFamily,
+			stun.AttrXORPeerAddress, stun.AttrChannelNumber, stun.AttrData:
+			continue
+		}
+		unknown = append(unknown, a.Type)
+	}
+	return unknown
+}
+
+// process builds response to request from client.
+func (s *Server) process(t transport, req, res *stun.Message) error {
+	errType := stun.NewType(req.Type.Method, stun.ClassErrorResponse)
+	if !s.allowedOrigin(t, req) {
+		return s.build(req, res, nil, req, errType, stun.CodeForbidden)
+	}
+	integrity, username, code := s.authenticate(t, req)
+	switch code {
+	case 0:
+	case stun.CodeUnauthorized, stun.CodeStaleNonce:
+		setters := []stun.Setter{req, errType, code, s.realm, s.nonce()}
+		if len(s.thirdParty) > 0 {
+			setters = append(setters, s.thirdParty)
+		}
+		return s.build(req, res, nil, setters...)
+	default:
+		return s.build(req, res, nil, req, errType, code)
+	}
+	if unknown := s.unknownAttributes(req); len(unknown) > 0 {
+		return s.build(req, res, integrity, req, errType, stun.CodeUnknownAttribute, unknown)
+	}
+	var setters []stun.Setter
+	switch req.Type.Method {
+	case stun.MethodBinding:
+		ip, port := addrIPPort(t.addr)
+		setters = append(setters, stun.XORMappedAddress{IP: ip, Port: port})
+	case stun.MethodAllocate:
+		setters, code = s.allocate(t, req, username)
+	case stun.MethodRefresh:
+		setters, code = s.refresh(t, req, username)
+	case stun.MethodCreatePermission:
+		code = s.createPermission(t, req, username)
+	case stun.MethodChannelBind:
+		code = s.channelBind(t, req, username)
+	default:
+		code = stun.CodeBadRequest
+	}
+	if code != 0 {
+		return s.build(req, res, integrity, req, errType, code)
+	}
+	successType := stun.NewType(req.Type.Method, stun.ClassSuccessResponse)
+	return s.build(req, res, integrity, append([]stun.Setter{req, successType}, setters...)...)
+}
+
+func (s *Server) build(req, res *stun.Message, integrity stun.MessageIntegrity, setters ...stun.Setter) error {
+	if len(s.software) > 0 {
+		setters = append(setters, &s.software)
+	}
+	if integrity != nil {
+		setters = append(setters, integrity)
+	}
+	if req.Contains(stun.AttrFingerprint) {
+		setters = append(setters, stun.Fingerprint)
+	}
+	return res.Build(setters...)
+}
+
+// lifetime returns desired lifetime of allocation for request.
+//
+// RFC 5766 Section 6.2 and Section 7.2
+func lifetime(req *stun.Message) time.Duration {
+	var l stun.Lifetime
+	if err := l.GetFrom(req); err != nil || l.Duration < DefaultLifetime {
+		return DefaultLifetime
+	}
+	if l.Duration > MaxLifetime {
+		return MaxLifetime
+	}
+	return l.Duration
+}
+
+// allocation returns allocation of client, s.mux must be locked.
+func (s *Server) allocation(t transport, username string) (*allocation, stun.ErrorCode) {
+	a, ok := s.allocations[t.tuple()]
+	if !ok {
+		return nil, stun.CodeAllocMismatch
+	}
+	if a.username != username {
+		return nil, stun.CodeWrongCredentials
+	}
+	return a, 0
+}
+
+func allocationSetters(a *allocation) []stun.Setter {
+	relayed := a.relay.LocalAddr().(*net.UDPAddr)
+	ip, port := addrIPPort(a.client.addr)
+	return []stun.Setter{
+		stun.XORRelayedAddress{IP: relayed.IP, Port: relayed.Port},
+		stun.Lifetime{Duration: a.lifetime},
+		stun.XORMappedAddress{IP: ip, Port: port},
+	}
+}
+
+// allocate handles Allocate request.
+//
+// RFC 5766 Section 6.2
+func (s *Server) allocate(t transport, req *stun.Message, username string) ([]stun.Setter, stun.ErrorCode) {
+	tuple := t.tuple()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		// Relay would not be closed or waited by Close.
+		return nil, stun.CodeInsufficientCapacity
+	}
+	if a, ok := s.allocations[tuple]; ok {
+		if a.tid == req.TransactionID && a.username == username {
+			// Retransmission of request.
+			return allocationSetters(a), 0
+		}
+		return nil, stun.CodeAllocMismatch
+	}
+	var (
+		transport stun.RequestedTransport
+		family    stun.RequestedAddressFamily
+	)
+	switch err := transport.GetFrom(req); err {
+	case nil:
+	case stun.ErrUnsupportedProtocol:
+		return nil, stun.CodeUnsupportedTransProto
+	default:
+		return nil, stun.CodeBadRequest
+	}
+	if transport.Protocol != stun.ProtoUDP {
+		return nil, stun.CodeUnsupportedTransProto
+	}
+	relayIP := s.relayIP
+	if relayIP == nil {
+		relayIP, _ = addrIPPort(t.local())
+	}
+	network := "udp4"
+	if relayIP.To4() == nil {
+		network = "udp6"
+	}
+	switch err := family.GetFrom(req); err {
+	case nil:
+		if (family == stun.RequestedFamilyIPv6) != (network == "udp6") {
+			return nil, stun.CodeAddrFamilyNotSupported
+		}
+	case stun.ErrAttributeNotFound:
+	case stun.ErrUnsupportedFamily:
+		return nil, stun.CodeAddrFamilyNotSupported
+	default:
+		return nil, stun.CodeBadRequest
+	}
+	if s.quota > 0 && s.allocated[username] >= s.quota {
+		return nil, stun.CodeAllocQuotaReached
+	}
+	if s.capacity > 0 && len(s.allocations) >= s.capacity {
+		return nil, stun.CodeInsufficientCapacity
+	}
+	relay, err := net.ListenUDP(network, &net.UDPAddr{IP: relayIP})
+	if err != nil {
+		return nil, stun.CodeInsufficientCapacity
+	}
+	a := &allocation{
+		tuple:    tuple,
+		client:   t,
+		username: username,
+		tid:      req.TransactionID,
+		relay:    relay,
+		lifetime: lifetime(req),
+		perms:    make(map[string]time.Time),
+		channels: make(map[stun.ChannelNumber]*binding),
+		peers:    make(map[string]*binding),
+	}
+	a.expires = s.clock.Now().Add(a.lifetime)
+	s.allocations[tuple] = a
+	s.allocated[username]++
+	s.wg.Add(1)
+	go s.serveRelay(a)
+	return allocationSetters(a), 0
+}
+
+// refresh handles Refresh request.
+//
+// RFC 5766 Section 7.2
+func (s *Server) refresh(t transport, req *stun.Message, username string) ([]stun.Setter, stun.ErrorCode) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	a, code := s.allocation(t, username)
+	if code != 0 {
+		return nil, code
+	}
+	var l stun.Lifetime
+	if err := l.GetFrom(req); err == nil && l.Duration == 0 {
+		s.deleteAllocation(a.tuple, a)
+		return []stun.Setter{stun.Lifetime{}}, 0
+	}
+	a.lifetime = lifetime(req)
+	a.expires = s.clock.Now().Add(a.lifetime)
+	return []stun.Setter{stun.Lifetime{Duration: a.lifetime}}, 0
+}
+
+// peerAddresses returns all XOR-PEER-ADDRESS attributes of m.
+func peerAddresses(m *stun.Message) ([]stun.XORPeerAddress, error) {
+	var (
+		peers []stun.XORPeerAddress
+		v     = &stun.Message{TransactionID: m.TransactionID}
+	)
+	for _, a := range m.Attributes {
+		if a.Type != stun.AttrXORPeerAddress {
+			continue
+		}
+		v.Reset()
+		v.Add(a.Type, a.Value)
+		var peer stun.XORPeerAddress
+		if err := peer.GetFrom(v); err != nil {
+			return nil, err
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+func sameFamily(a *allocation, ip net.IP) bool {
+	return (a.relay.LocalAddr().(*net.UDPAddr).IP.To4() == nil) == (ip.To4() == nil)
+}
+
+// createPermission handles CreatePermission request.
+//
+// RFC 5766 Section 9.2
+func (s *Server) createPermission(t transport, req *stun.Message, username string) stun.ErrorCode {
+	peers, err := peerAddresses(req)
+	if err != nil || len(peers) == 0 {
+		return stun.CodeBadRequest
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	a, code := s.allocation(t, username)
+	if code != 0 {
+		return code
+	}
+	for _, peer := range peers {
+		if !sameFamily(a, peer.IP) {
+			return stun.CodePeerAddrFamilyMismatch
+		}
+	}
+	expires := s.clock.Now().Add(PermissionLifetime)
+	for _, peer := range peers {
+		a.perms[peer.IP.String()] = expires
+	}
+	return 0
+}
+
+// channelBind handles ChannelBind request.
+//
+// RFC 5766 Section 11.2
+func (s *Server) channelBind(t transport, req *stun.Message, username string) stun.ErrorCode {
+	var (
+		number stun.ChannelNumber
+		peer   stun.XORPeerAddress
+	)
+	if err := req.Parse(&number, &peer); err != nil {
+		return stun.CodeBadRequest
+	}
+	addr := &net.UDPAddr{IP: peer.IP, Port: peer.Port}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	a, code := s.allocation(t, username)
+	if code != 0 {
+		return code
+	}
+	if !sameFamily(a, peer.IP) {
+		return stun.CodePeerAddrFamilyMismatch
+	}
+	b, ok := a.channels[number]
+	if ok && b.peer.String() != addr.String() {
+		return stun.CodeBadRequest
+	}
+	if bound, ok := a.peers[addr.String()]; ok && bound.number != number {
+		return stun.CodeBadRequest
+	}
+	if !ok {
+		b = &binding{number: number, peer: addr}
+		a.channels[number] = b
+		a.peers[addr.String()] = b
+	}
+	now := s.clock.Now()
+	b.expires = now.Add(ChannelBindingLifetime)
+	a.perms[peer.IP.String()] = now.Add(PermissionLifetime)
+	return 0
+}
+
+// handleSend relays data of Send indication to peer.
+//
+// RFC 5766 Section 10.2
+func (s *Server) handleSend(t transport, m *stun.Message) {
+	var (
+		peer stun.XORPeerAddress
+		data stun.Data
+	)
+	if err := m.Parse(&peer, &data); err != nil {
+		return
+	}
+	s.mux.Lock()
+	a, ok := s.allocations[t.tuple()]
+	permitted := ok && a.permitted(peer.IP, s.clock.Now())
+	s.mux.Unlock()
+	if !permitted {
+		return
+	}
+	_, _ = a.relay.WriteTo(data, &net.UDPAddr{IP: peer.IP, Port: peer.Port})
+}
+
+// handleChannelData relays data of ChannelData message to peer.
+//
+// RFC 5766 Section 11.6
+func (s *Server) handleChannelData(t transport, b []byte) {
+	d := &stun.ChannelData{Raw: b}
+	if err := d.Decode(); err != nil {
+		return
+	}
+	var (
+		now       = s.clock.Now()
+		relay     net.PacketConn
+		peer      *net.UDPAddr
+		permitted bool
+	)
+	s.mux.Lock()
+	if a, ok := s.allocations[t.tuple()]; ok {
+		if ch, bound := a.channels[d.Number]; bound && now.Before(ch.expires) {
+			relay, peer = a.relay, ch.peer
+			permitted = a.permitted(peer.IP, now)
+		}
+	}
+	s.mux.Unlock()
+	if !permitted {
+		return
+	}
+	_, _ = relay.WriteTo(d.Data, peer)
+}
+
+// serveRelay relays data from peers to client via ChannelData messages if
+// channel is bound to peer or Data indications otherwise, until relay is
+// closed.
+//
+// RFC 5766 Section 10.3 and Section 11.5
+func (s *Server) serveRelay(a *allocation) {
+	defer s.wg.Done()
+	var (
+		buf = make([]byte, maxPacketSize)
+		d   = new(stun.ChannelData)
+		m   = new(stun.Message)
+	)
+	for {
+		n, addr, err := a.relay.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		peer, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		var (
+			now    = s.clock.Now()
+			number stun.ChannelNumber
+		)
+		s.mux.Lock()
+		permitted := a.permitted(peer.IP, now)
+		if b, bound := a.peers[peer.String()]; bound && now.Before(b.expires) {
+			number = b.number
+		}
+		s.mux.Unlock()
+		if !permitted {
+			continue
+		}
+		var raw []byte
+		if number != 0 {
+			d.Number, d.Data = number, buf[:n]
+			if a.client.stream != nil {
+				d.EncodePadded()
+			} else {
+				d.Encode()
+			}
+			raw = d.Raw
+		} else {
+			if err = m.Build(stun.TransactionID, dataIndication,
+				stun.XORPeerAddress{IP: peer.IP, Port: peer.Port}, stun.Data(buf[:n]),
+			); err != nil {
+				continue
+			}
+			raw = m.Raw
+		}
+		_ = a.client.write(raw)
+	}
+}
@@ -0,0 +1,674 @@
+package turn
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+func listenServer(t *testing.T, options ...ServerOption) (*Server, net.Addr) {
+	t.Helper()
+	s, err := NewServer(append([]ServerOption{
+		WithRealm(testRealm), WithUser(testUsername, testPassword),
+	}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := listenUDP(t)
+	go func() {
+		_ = s.Serve(conn)
+	}()
+	return s, conn.LocalAddr()
+}
+
+// request sends request from conn to server and returns response.
+func request(t *testing.T, conn net.PacketConn, server net.Addr, setters ...stun.Setter) *stun.Message {
+	t.Helper()
+	req := stun.MustBuild(append([]stun.Setter{stun.TransactionID}, setters...)...)
+	return roundTrip(t, conn, server, req)
+}
+
+func roundTrip(t *testing.T, conn net.PacketConn, server net.Addr, req *stun.Message) *stun.Message {
+	t.Helper()
+	if _, err := conn.WriteTo(req.Raw, server); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		b, _ := readFrom(t, conn)
+		res := new(stun.Message)
+		if _, err := res.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		if res.TransactionID == req.TransactionID {
+			return res
+		}
+	}
+}
+
+// challenge returns nonce from response to unauthenticated request.
+func challenge(t *testing.T, conn net.PacketConn, server net.Addr) stun.Nonce {
+	t.Helper()
+	res := request(t, conn, server, stun.BindingRequest)
+	var (
+		nonce stun.Nonce
+		realm stun.Realm
+	)
+	if code := errorCode(t, res); code != stun.CodeUnauthorized {
+		t.Fatalf("unexpected code %d", code)
+	}
+	if err := res.Parse(&nonce, &realm); err != nil {
+		t.Fatal(err)
+	}
+	if realm.String() != testRealm {
+		t.Errorf("unexpected realm %s", realm)
+	}
+	return nonce
+}
+
+// authenticated returns setters with long-term credentials.
+func authenticated(nonce stun.Nonce, username, password string, setters ...stun.Setter) []stun.Setter {
+	return append(setters,
+		stun.NewUsername(username), stun.NewRealm(testRealm), nonce,
+		stun.NewLongTermIntegrity(username, testRealm, password),
+		stun.Fingerprint,
+	)
+}
+
+func errorCode(t *testing.T, res *stun.Message) stun.ErrorCode {
+	t.Helper()
+	if res.Type.Class == stun.ClassSuccessResponse {
+		return 0
+	}
+	var code stun.ErrorCodeAttribute
+	if err := code.GetFrom(res); err != nil {
+		t.Fatal(err)
+	}
+	return code.Code
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer(WithRealm(testRealm), WithUser(testUsername, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		serverConn = listenUDP(t)
+		served     = make(chan error, 1)
+	)
+	go func() {
+		served <- s.Serve(serverConn)
+	}()
+	conn := listenUDP(t)
+	defer conn.Close()
+	c, err := Allocate(conn, serverConn.LocalAddr(), WithCredentials(testUsername, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Mapped().String() != conn.LocalAddr().String() {
+		t.Errorf("bad mapped address %s", c.Mapped())
+	}
+	peer := listenUDP(t)
+	defer peer.Close()
+	buf := make([]byte, 100)
+	for _, bind := range []bool{false, true} {
+		if bind {
+			if _, err = c.Bind(peer.LocalAddr()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err = c.WriteTo([]byte("hello"), peer.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		data, from := readFrom(t, peer)
+		if string(data) != "hello" || from.String() != c.Relayed().String() {
+			t.Errorf("unexpected %q from %s", data, from)
+		}
+		if _, err = peer.WriteTo([]byte("world"), c.Relayed()); err != nil {
+			t.Fatal(err)
+		}
+		if err = c.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+		n, from, err := c.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != "world" || from.String() != peer.LocalAddr().String() {
+			t.Errorf("unexpected %q from %s", buf[:n], from)
+		}
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.mux.Lock()
+	if len(s.allocations) != 0 || len(s.allocated) != 0 {
+		t.Error("allocation should be deleted")
+	}
+	s.mux.Unlock()
+	if err = s.Close(); err != nil {
+		t.Error(err)
+	}
+	if err = <-served; err != ErrServerClosed {
+		t.Errorf("unexpected serve error %v", err)
+	}
+	if err = s.Close(); err != ErrServerClosed {
+		t.Error("second close should fail")
+	}
+	if err = s.Serve(conn); err != ErrServerClosed {
+		t.Errorf("unexpected serve error %v", err)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	s, addr := listenServer(t, WithUser("other", "password"))
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	nonce := challenge(t, conn, addr)
+	udp := stun.RequestedTransport{Protocol: stun.ProtoUDP}
+	for _, tc := range []struct {
+		name    string
+		setters []stun.Setter
+		code    stun.ErrorCode
+	}{
+		{"UnknownUser", authenticated(nonce, "unknown", testPassword, stun.BindingRequest), stun.CodeUnauthorized},
+		{"WrongPassword", authenticated(nonce, testUsername, "bad", stun.BindingRequest), stun.CodeUnauthorized},
+		{"BadNonce", authenticated(stun.NewNonce("nonce"), testUsername, testPassword, stun.BindingRequest), stun.CodeStaleNonce},
+		{"NoUsername", []stun.Setter{stun.BindingRequest, stun.NewRealm(testRealm), nonce,
+			stun.NewLongTermIntegrity(testUsername, testRealm, testPassword)}, stun.CodeBadRequest},
+		{"UnknownMethod", authenticated(nonce, testUsername, testPassword,
+			stun.NewType(stun.MethodConnect, stun.ClassRequest)), stun.CodeBadRequest},
+		{"UnknownAttribute", authenticated(nonce, testUsername, testPassword,
+			allocateRequest, udp, stun.EvenPort{}), stun.CodeUnknownAttribute},
+		{"NoTransport", authenticated(nonce, testUsername, testPassword, allocateRequest), stun.CodeBadRequest},
+		{"TCP", authenticated(nonce, testUsername, testPassword,
+			allocateRequest, stun.RequestedTransport{Protocol: stun.ProtoTCP}), stun.CodeUnsupportedTransProto},
+		{"IPv6", authenticated(nonce, testUsername, testPassword,
+			allocateRequest, udp, stun.RequestedFamilyIPv6), stun.CodeAddrFamilyNotSupported},
+		{"NoAllocation", authenticated(nonce, testUsername, testPassword, refreshRequest), stun.CodeAllocMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := request(t, conn, addr, tc.setters...)
+			if code := errorCode(t, res); code != tc.code {
+				t.Errorf("unexpected code %d", code)
+			}
+			if tc.code == stun.CodeUnauthorized || tc.code == stun.CodeStaleNonce {
+				if res.Contains(stun.AttrMessageIntegrity) || !res.Contains(stun.AttrNonce) {
+					t.Error("should contain nonce and no integrity")
+				}
+			}
+		})
+	}
+	t.Run("Binding", func(t *testing.T) {
+		res := request(t, conn, addr, authenticated(nonce, testUsername, testPassword, stun.BindingRequest)...)
+		var mapped stun.XORMappedAddress
+		if err := res.Parse(&mapped); err != nil {
+			t.Fatal(err)
+		}
+		if mapped.String() != conn.LocalAddr().String() {
+			t.Errorf("unexpected mapped address %s", mapped)
+		}
+		if err := stun.Fingerprint.Check(res); err != nil {
+			t.Error(err)
+		}
+		if err := stun.NewLongTermIntegrity(testUsername, testRealm, testPassword).Check(res); err != nil {
+			t.Error(err)
+		}
+	})
+	allocate := stun.MustBuild(authenticated(nonce, testUsername, testPassword,
+		stun.TransactionID, allocateRequest, udp, stun.Lifetime{Duration: time.Hour * 2},
+	)...)
+	res := roundTrip(t, conn, addr, allocate)
+	var (
+		relayed  stun.XORRelayedAddress
+		lifetime stun.Lifetime
+	)
+	if err := res.Parse(&relayed, &lifetime); err != nil {
+		t.Fatal(err)
+	}
+	if lifetime.Duration != MaxLifetime {
+		t.Errorf("unexpected lifetime %s", lifetime.Duration)
+	}
+	peer := stun.XORPeerAddress{IP: net.IPv4(127, 0, 0, 1), Port: 1000}
+	for _, tc := range []struct {
+		name    string
+		setters []stun.Setter
+		code    stun.ErrorCode
+	}{
+		{"AllocationMismatch", authenticated(nonce, testUsername, testPassword, allocateRequest, udp), stun.CodeAllocMismatch},
+		{"WrongCredentials", authenticated(nonce, "other", "password", refreshRequest), stun.CodeWrongCredentials},
+		{"NoPeer", authenticated(nonce, testUsername, testPassword, createPermissionRequest), stun.CodeBadRequest},
+		{"PeerFamily", authenticated(nonce, testUsername, testPassword,
+			createPermissionRequest, peer, stun.XORPeerAddress{IP: net.IPv6loopback}), stun.CodePeerAddrFamilyMismatch},
+		{"Permission", authenticated(nonce, testUsername, testPassword, createPermissionRequest, peer), 0},
+		{"BadChannel", authenticated(nonce, testUsername, testPassword,
+			channelBindRequest, stun.RawAttribute{Type: stun.AttrChannelNumber, Value: []byte{0x10, 0, 0, 0}}, peer),
+			stun.CodeBadRequest},
+		{"Channel", authenticated(nonce, testUsername, testPassword,
+			channelBindRequest, stun.MinChannelNumber, peer), 0},
+		{"ChannelRefresh", authenticated(nonce, testUsername, testPassword,
+			channelBindRequest, stun.MinChannelNumber, peer), 0},
+		{"ChannelPeerMismatch", authenticated(nonce, testUsername, testPassword,
+			channelBindRequest, stun.MinChannelNumber, stun.XORPeerAddress{IP: peer.IP, Port: 1001}), stun.CodeBadRequest},
+		{"ChannelNumberMismatch", authenticated(nonce, testUsername, testPassword,
+			channelBindRequest, stun.MinChannelNumber+1, peer), stun.CodeBadRequest},
+		{"Refresh", authenticated(nonce, testUsername, testPassword, refreshRequest), 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := request(t, conn, addr, tc.setters...)
+			if code := errorCode(t, res); code != tc.code {
+				t.Errorf("unexpected code %d", code)
+			}
+		})
+	}
+	t.Run("Retransmission", func(t *testing.T) {
+		res := roundTrip(t, conn, addr, allocate)
+		var again stun.XORRelayedAddress
+		if err := again.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if again.String() != relayed.String() {
+			t.Errorf("%s != %s", again, relayed)
+		}
+	})
+	t.Run("Delete", func(t *testing.T) {
+		res := request(t, conn, addr, authenticated(nonce, testUsername, testPassword,
+			refreshRequest, stun.Lifetime{},
+		)...)
+		if err := lifetime.GetFrom(res); err != nil {
+			t.Fatal(err)
+		}
+		if lifetime.Duration != 0 {
+			t.Errorf("unexpected lifetime %s", lifetime.Duration)
+		}
+		res = request(t, conn, addr, authenticated(nonce, testUsername, testPassword, refreshRequest)...)
+		if code := errorCode(t, res); code != stun.CodeAllocMismatch {
+			t.Errorf("unexpected code %d", code)
+		}
+	})
+}
+
+func TestServer_Limits(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		option ServerOption
+		code   stun.ErrorCode
+	}{
+		{"Quota", WithQuota(1), stun.CodeAllocQuotaReached},
+		{"Capacity", WithCapacity(1), stun.CodeInsufficientCapacity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, addr := listenServer(t, tc.option)
+			defer s.Close()
+			first := listenUDP(t)
+			defer first.Close()
+			c, err := Allocate(first, addr, WithCredentials(testUsername, testPassword))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			second := listenUDP(t)
+			defer second.Close()
+			_, err = Allocate(second, addr, WithCredentials(testUsername, testPassword))
+			if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != tc.code {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestServer_CloseDuringAllocate(t *testing.T) {
+	var s *Server
+	s, err := NewServer(WithRealm(testRealm), WithAuthHandler(
+		func(username, realm string, addr net.Addr) (stun.MessageIntegrity, bool) {
+			// Closing server while Allocate request is processed.
+			if closeErr := s.Close(); closeErr != nil {
+				t.Error(closeErr)
+			}
+			return stun.NewLongTermIntegrity(username, realm, testPassword), true
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := listenUDP(t)
+	defer conn.Close()
+	client := listenUDP(t)
+	defer client.Close()
+	req := stun.MustBuild(append([]stun.Setter{stun.TransactionID},
+		authenticated(s.nonce(), testUsername, testPassword, allocateRequest,
+			stun.RequestedTransport{Protocol: stun.ProtoUDP},
+		)...)...)
+	res := new(stun.Message)
+	if err = s.process(transport{packet: conn, addr: client.LocalAddr()}, req, res); err != nil {
+		t.Fatal(err)
+	}
+	if code := errorCode(t, res); code != stun.CodeInsufficientCapacity {
+		t.Errorf("unexpected code %d", code)
+	}
+	s.mux.Lock()
+	if len(s.allocations) != 0 {
+		t.Error("allocation should not be created")
+	}
+	s.mux.Unlock()
+}
+
+func TestServer_Origin(t *testing.T) {
+	var (
+		mux sync.Mutex
+		got []string
+	)
+	origins := func() []string {
+		mux.Lock()
+		defer mux.Unlock()
+		return got
+	}
+	s, addr := listenServer(t, WithOriginHandler(func(origins []string, addr net.Addr) bool {
+		mux.Lock()
+		got = origins
+		mux.Unlock()
+		return len(origins) == 1 && origins[0] == "https://example.com"
+	}))
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	res := request(t, conn, addr, stun.BindingRequest)
+	if code := errorCode(t, res); code != stun.CodeForbidden {
+		t.Errorf("unexpected code %d", code)
+	}
+	if len(origins()) != 0 {
+		t.Errorf("unexpected origins %v", origins())
+	}
+	res = request(t, conn, addr, stun.BindingRequest, stun.NewOrigin("https://example.com"))
+	if code := errorCode(t, res); code != stun.CodeUnauthorized {
+		t.Errorf("unexpected code %d", code)
+	}
+	var nonce stun.Nonce
+	if err := nonce.GetFrom(res); err != nil {
+		t.Fatal(err)
+	}
+	res = request(t, conn, addr, authenticated(nonce, testUsername, testPassword,
+		stun.BindingRequest, stun.NewOrigin("https://example.com"))...)
+	if res.Type != stun.BindingSuccess {
+		t.Errorf("unexpected response %s", res.Type)
+	}
+	res = request(t, conn, addr, authenticated(nonce, testUsername, testPassword, stun.BindingRequest,
+		stun.Origins{stun.NewOrigin("https://example.com"), stun.NewOrigin("https://evil.com")})...)
+	if code := errorCode(t, res); code != stun.CodeForbidden {
+		t.Errorf("unexpected code %d", code)
+	}
+	if got := origins(); len(got) != 2 || got[1] != "https://evil.com" {
+		t.Errorf("unexpected origins %v", got)
+	}
+}
+
+func TestServer_ThirdPartyAuthorization(t *testing.T) {
+	const (
+		server = "turn.example.org"
+		kid    = "key-1"
+	)
+	codec, err := stun.NewTokenCodec(make([]byte, 16), server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, addr := listenServer(t, WithThirdPartyAuthorization(server, func(id string) (*stun.TokenCodec, bool) {
+		return codec, id == kid
+	}))
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	res := request(t, conn, addr, stun.BindingRequest)
+	var thirdParty stun.ThirdPartyAuthorization
+	if err = thirdParty.GetFrom(res); err != nil {
+		t.Fatal(err)
+	}
+	if thirdParty.String() != server {
+		t.Errorf("unexpected server name %s", thirdParty)
+	}
+	macKey := []byte("0123456789abcdef0123")
+	accessToken := func(t *testing.T, timestamp time.Time) stun.AccessToken {
+		t.Helper()
+		token, err := codec.Encrypt(stun.Token{MACKey: macKey, Timestamp: timestamp, Lifetime: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	t.Run("Allocate", func(t *testing.T) {
+		c, err := Allocate(conn, addr, WithAccessToken(kid, accessToken(t, time.Now()), macKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, tc := range []struct {
+		name      string
+		kid       string
+		timestamp time.Time
+	}{
+		{"Expired", kid, time.Now().Add(-time.Hour * 2)},
+		{"UnknownKey", "key-2", time.Now()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := listenUDP(t)
+			defer client.Close()
+			_, err := Allocate(client, addr, WithAccessToken(tc.kid, accessToken(t, tc.timestamp), macKey))
+			if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != stun.CodeUnauthorized {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+	t.Run("NotSupported", func(t *testing.T) {
+		s, addr := listenServer(t)
+		defer s.Close()
+		nonce := challenge(t, conn, addr)
+		res := request(t, conn, addr, authenticated(nonce, testUsername, testPassword,
+			stun.BindingRequest, accessToken(t, time.Now()))...)
+		if code := errorCode(t, res); code != stun.CodeUnknownAttribute {
+			t.Errorf("unexpected code %d", code)
+		}
+		if res.Contains(stun.AttrThirdPartyAuthorization) {
+			t.Error("should not contain THIRD-PARTY-AUTHORIZATION")
+		}
+	})
+}
+
+func TestServer_EphemeralCredentials(t *testing.T) {
+	clock := &manualClock{current: time.Now()}
+	credentials := stun.NewEphemeralCredentials("secret", time.Hour, stun.WithEphemeralClock(clock))
+	s, addr := listenServer(t, WithEphemeralCredentials(credentials))
+	defer s.Close()
+	username, password := credentials.Generate("alice")
+	t.Run("Allocate", func(t *testing.T) {
+		conn := listenUDP(t)
+		defer conn.Close()
+		c, err := Allocate(conn, addr, WithCredentials(username, password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, tc := range []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"WrongPassword", username, "password"},
+		{"StaticUser", testUsername, testPassword},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := listenUDP(t)
+			defer conn.Close()
+			_, err := Allocate(conn, addr, WithCredentials(tc.username, tc.password))
+			if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != stun.CodeUnauthorized {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+	t.Run("Expired", func(t *testing.T) {
+		clock.Add(time.Hour)
+		conn := listenUDP(t)
+		defer conn.Close()
+		_, err := Allocate(conn, addr, WithCredentials(username, password))
+		if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != stun.CodeUnauthorized {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestServer_Expiration(t *testing.T) {
+	var (
+		clock     = &manualClock{current: time.Now()}
+		collector = new(manualCollector)
+	)
+	s, addr := listenServer(t, WithServerClock(clock), WithServerCollector(collector))
+	defer s.Close()
+	conn := listenUDP(t)
+	defer conn.Close()
+	// Client never refreshes allocation.
+	c, err := Allocate(conn, addr,
+		WithCredentials(testUsername, testPassword), WithLifetime(time.Minute*30),
+		WithCollector(new(manualCollector)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := listenUDP(t)
+	defer peer.Close()
+	if _, err = c.Bind(peer.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	allocation := func() *allocation {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		for _, a := range s.allocations {
+			return a
+		}
+		return nil
+	}
+	collector.f(clock.Add(PermissionLifetime))
+	a := allocation()
+	s.mux.Lock()
+	if len(a.perms) != 0 || len(a.channels) != 1 {
+		t.Error("only permission should expire")
+	}
+	s.mux.Unlock()
+	collector.f(clock.Add(ChannelBindingLifetime - PermissionLifetime))
+	s.mux.Lock()
+	if len(a.channels) != 0 || s.allocations[a.tuple] != a {
+		t.Error("only channel should expire")
+	}
+	s.mux.Unlock()
+	// Nonce is stale now, client re-authenticates.
+	if err = c.CreatePermission(peer.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	collector.f(clock.Add(time.Minute * 20))
+	if allocation() != nil {
+		t.Fatal("allocation should expire")
+	}
+	err = c.Close()
+	if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != stun.CodeAllocMismatch {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestServer_TCP(t *testing.T) {
+	s, err := NewServer(WithRealm(testRealm), WithUser(testUsername, testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = s.ServeTCP(l)
+	}()
+	conn, err := net.Dial("tcp4", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.SetDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	r := stun.NewStreamReader(conn)
+	do := func(setters ...stun.Setter) *stun.Message {
+		req := stun.MustBuild(append([]stun.Setter{stun.TransactionID}, setters...)...)
+		if _, err = conn.Write(req.Raw); err != nil {
+			t.Fatal(err)
+		}
+		frame, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := new(stun.Message)
+		if _, err = res.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+		if res.Type.Class != stun.ClassSuccessResponse && res.Type != stun.BindingError {
+			t.Fatalf("unexpected response %s", res)
+		}
+		return res
+	}
+	var nonce stun.Nonce
+	if err = do(stun.BindingRequest).Parse(&nonce); err != nil {
+		t.Fatal(err)
+	}
+	var relayed stun.XORRelayedAddress
+	if err = do(authenticated(nonce, testUsername, testPassword,
+		allocateRequest, stun.RequestedTransport{Protocol: stun.ProtoUDP},
+	)...).Parse(&relayed); err != nil {
+		t.Fatal(err)
+	}
+	peer := listenUDP(t)
+	defer peer.Close()
+	peerAddr := peer.LocalAddr().(*net.UDPAddr)
+	do(authenticated(nonce, testUsername, testPassword,
+		channelBindRequest, stun.MinChannelNumber, stun.XORPeerAddress{IP: peerAddr.IP, Port: peerAddr.Port},
+	)...)
+	d := &stun.ChannelData{Number: stun.MinChannelNumber, Data: []byte{1, 2, 3}}
+	d.EncodePadded()
+	if _, err = conn.Write(d.Raw); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := readFrom(t, peer); len(data) != 3 {
+		t.Errorf("unexpected data %v", data)
+	}
+	if _, err = peer.WriteTo([]byte{4, 5}, &net.UDPAddr{IP: relayed.IP, Port: relayed.Port}); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &stun.ChannelData{Raw: frame}
+	if err = got.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if got.Number != stun.MinChannelNumber || len(got.Data) != 2 {
+		t.Errorf("unexpected channel data %v", got)
+	}
+	// Allocation is deleted when connection is closed.
+	if err = conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		s.mux.Lock()
+		n := len(s.allocations)
+		s.mux.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	t.Error("allocation should be deleted")
+}
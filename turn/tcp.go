@@ -0,0 +1,261 @@
+package turn
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"gortc.io/stun"
+)
+
+// connectionTimeout is time after which server closes peer data connection
+// if client data connection is not bound to it.
+//
+// RFC 6062 Section 5.3
+const connectionTimeout = time.Second * 30
+
+// attemptQueueSize is size of incoming connection attempts queue, attempts
+// are dropped if queue is full.
+const attemptQueueSize = 16
+
+// connectionAttempt is incoming connection from peer.
+type connectionAttempt struct {
+	id   stun.ConnectionID
+	peer *net.UDPAddr
+}
+
+// TCPClient is TURN client of TCP allocation, where client connects to
+// peers or accepts connections from peers via relayed transport address,
+// each connection is separate data connection to server.
+//
+// TCPClient implements net.Listener, Accept returns connections from peers
+// with permissions. Allocation and permissions are refreshed automatically
+// until Close.
+//
+// RFC 6062
+type TCPClient struct {
+	client   *Client
+	dial     func() (net.Conn, error)
+	attempts chan connectionAttempt
+}
+
+// AllocateTCP requests TCP allocation from server over control connection
+// that is returned by dial, which is also used to open data connections.
+// Control connection is closed on Close.
+//
+// RFC 6062 Section 4.1
+func AllocateTCP(dial func() (net.Conn, error), options ...ClientOption) (*TCPClient, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c := &TCPClient{
+		dial:     dial,
+		attempts: make(chan connectionAttempt, attemptQueueSize),
+	}
+	client := newClient(append([]ClientOption{
+		WithSTUNOptions(stun.WithNoRetransmit),
+	}, options...))
+	client.conn = &streamConn{
+		conn:      conn,
+		r:         stun.NewStreamReader(conn),
+		m:         new(stun.Message),
+		onAttempt: c.handleAttempt,
+	}
+	if err = client.allocate(stun.ProtoTCP); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c.client = client
+	return c, nil
+}
+
+// Addr returns relayed transport address.
+func (c *TCPClient) Addr() net.Addr {
+	return c.Relayed()
+}
+
+// Relayed returns relayed transport address of allocation.
+func (c *TCPClient) Relayed() *net.TCPAddr {
+	return &net.TCPAddr{IP: c.client.relayed.IP, Port: c.client.relayed.Port}
+}
+
+// CreatePermission installs or refreshes permissions for IP addresses of
+// peers, ports are ignored. Permission is required to accept connection
+// from peer.
+//
+// RFC 6062 Section 4.4
+func (c *TCPClient) CreatePermission(peers ...net.Addr) error {
+	return c.client.CreatePermission(peers...)
+}
+
+// Connect connects to peer through relay, creating permission for peer IP
+// if needed, and returns data connection.
+//
+// RFC 6062 Section 4.3
+func (c *TCPClient) Connect(addr net.Addr) (net.Conn, error) {
+	peer, err := peerAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.client.permit(peer); err != nil {
+		return nil, err
+	}
+	res, err := c.client.do(connectRequest, stun.XORPeerAddress{IP: peer.IP, Port: peer.Port})
+	if err != nil {
+		return nil, err
+	}
+	var id stun.ConnectionID
+	if err = id.GetFrom(res); err != nil {
+		return nil, err
+	}
+	return c.bind(id, peer)
+}
+
+// Accept waits for connection attempt from peer and returns data
+// connection to it.
+//
+// RFC 6062 Section 4.4
+func (c *TCPClient) Accept() (net.Conn, error) {
+	select {
+	case a := <-c.attempts:
+		return c.bind(a.id, a.peer)
+	case <-c.client.done:
+		return nil, ErrClientClosed
+	}
+}
+
+// Close deletes allocation and closes control connection, data
+// connections are not closed.
+func (c *TCPClient) Close() error {
+	return c.client.Close()
+}
+
+// handleAttempt handles ConnectionAttempt indication.
+//
+// RFC 6062 Section 4.4
+func (c *TCPClient) handleAttempt(m *stun.Message) {
+	var (
+		id   stun.ConnectionID
+		peer stun.XORPeerAddress
+	)
+	if err := m.Parse(&id, &peer); err != nil {
+		return
+	}
+	select {
+	case c.attempts <- connectionAttempt{id: id, peer: &net.UDPAddr{IP: peer.IP, Port: peer.Port}}:
+	default:
+	}
+}
+
+// bind opens new data connection and binds it to peer connection.
+//
+// RFC 6062 Section 4.3
+func (c *TCPClient) bind(id stun.ConnectionID, peer *net.UDPAddr) (net.Conn, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Now().Add(connectionTimeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if _, err = c.client.doWith(func(req *stun.Message) (*stun.Message, error) {
+		if _, writeErr := conn.Write(req.Raw); writeErr != nil {
+			return nil, writeErr
+		}
+		res, readErr := readMessage(conn)
+		if readErr != nil {
+			return nil, readErr
+		}
+		if res.TransactionID != req.TransactionID {
+			return nil, ErrUnexpectedResponse
+		}
+		return res, nil
+	}, connectionBindRequest, id); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &peerConn{
+		Conn:   conn,
+		local:  c.Relayed(),
+		remote: &net.TCPAddr{IP: peer.IP, Port: peer.Port, Zone: peer.Zone},
+	}, nil
+}
+
+const messageHeaderSize = 20
+
+// readMessage reads single STUN message from r, not reading anything after
+// it, because data connection carries application data after
+// ConnectionBind response.
+func readMessage(r io.Reader) (*stun.Message, error) {
+	m := &stun.Message{Raw: make([]byte, messageHeaderSize)}
+	if _, err := io.ReadFull(r, m.Raw); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(m.Raw[2:4]))
+	m.Raw = append(m.Raw, make([]byte, length)...)
+	if _, err := io.ReadFull(r, m.Raw[messageHeaderSize:]); err != nil {
+		return nil, err
+	}
+	if err := m.Decode(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// peerConn is data connection to peer through relay.
+type peerConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+// LocalAddr returns relayed transport address.
+func (c *peerConn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr returns address of peer.
+func (c *peerConn) RemoteAddr() net.Addr { return c.remote }
+
+// streamConn adapts control connection to stun.Connection, framing
+// messages and demultiplexing ConnectionAttempt indications.
+type streamConn struct {
+	conn      net.Conn
+	r         *stun.StreamReader // accessed only by reading goroutine
+	m         *stun.Message      // accessed only by reading goroutine
+	onAttempt func(m *stun.Message)
+}
+
+func (s *streamConn) Read(b []byte) (int, error) {
+	for {
+		frame, err := s.r.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !stun.IsMessage(frame) {
+			continue
+		}
+		s.m.Raw = append(s.m.Raw[:0], frame...)
+		if err = s.m.Decode(); err != nil {
+			continue
+		}
+		if s.m.Type == connectionAttemptIndication {
+			s.onAttempt(s.m)
+			continue
+		}
+		return copy(b, frame), nil
+	}
+}
+
+func (s *streamConn) Write(b []byte) (int, error) {
+	return s.conn.Write(b)
+}
+
+func (s *streamConn) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,328 @@
+package turn
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"gortc.io/stun"
+)
+
+// tcpServer is stand-in TURN server of TCP allocations without
+// authentication that serves single allocation.
+type tcpServer struct {
+	t  *testing.T
+	l  net.Listener
+	wg sync.WaitGroup
+
+	mux     sync.Mutex
+	control net.Conn
+	relay   net.Listener
+	nextID  stun.ConnectionID
+	pending map[stun.ConnectionID]net.Conn // peer connections by id
+	perms   map[string]bool
+}
+
+func newTCPServer(t *testing.T) *tcpServer {
+	t.Helper()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &tcpServer{
+		t:       t,
+		l:       l,
+		nextID:  1,
+		pending: make(map[stun.ConnectionID]net.Conn),
+		perms:   make(map[string]bool),
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *tcpServer) dial() (net.Conn, error) {
+	return net.Dial("tcp4", s.l.Addr().String())
+}
+
+func (s *tcpServer) Close() {
+	_ = s.l.Close()
+	s.mux.Lock()
+	if s.relay != nil {
+		_ = s.relay.Close()
+	}
+	s.mux.Unlock()
+	s.wg.Wait()
+}
+
+func (s *tcpServer) handle(conn net.Conn) {
+	for {
+		m, err := readMessage(conn)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		if m.Type == connectionBindRequest {
+			s.bind(conn, m)
+			return
+		}
+		res := s.process(conn, m)
+		if _, err = conn.Write(res.Raw); err != nil {
+			s.t.Error(err)
+		}
+	}
+}
+
+func (s *tcpServer) process(conn net.Conn, m *stun.Message) *stun.Message {
+	var (
+		success = stun.NewType(m.Type.Method, stun.ClassSuccessResponse)
+		peer    stun.XORPeerAddress
+	)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch m.Type.Method {
+	case stun.MethodAllocate:
+		var transport stun.RequestedTransport
+		if err := transport.GetFrom(m); err != nil || transport.Protocol != stun.ProtoTCP {
+			s.t.Error("unexpected transport")
+		}
+		relay, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		s.control, s.relay = conn, relay
+		s.wg.Add(1)
+		go s.acceptPeers(relay)
+		relayed := relay.Addr().(*net.TCPAddr)
+		return stun.MustBuild(m, success,
+			stun.XORRelayedAddress{IP: relayed.IP, Port: relayed.Port},
+			stun.Lifetime{Duration: DefaultLifetime},
+		)
+	case stun.MethodRefresh:
+		var lifetime stun.Lifetime
+		if err := lifetime.GetFrom(m); err == nil && lifetime.Duration == 0 {
+			_ = s.relay.Close()
+		}
+		return stun.MustBuild(m, success, lifetime)
+	case stun.MethodCreatePermission:
+		if err := peer.GetFrom(m); err != nil {
+			s.t.Error(err)
+		}
+		s.perms[peer.IP.String()] = true
+		return stun.MustBuild(m, success)
+	case stun.MethodConnect:
+		if err := peer.GetFrom(m); err != nil {
+			s.t.Error(err)
+		}
+		if !s.perms[peer.IP.String()] {
+			return stun.MustBuild(m, stun.NewType(m.Type.Method, stun.ClassErrorResponse), stun.CodeForbidden)
+		}
+		peerConn, err := net.Dial("tcp4", peer.String())
+		if err != nil {
+			return stun.MustBuild(m, stun.NewType(m.Type.Method, stun.ClassErrorResponse), stun.CodeConnTimeoutOrFailure)
+		}
+		id := s.nextID
+		s.nextID++
+		s.pending[id] = peerConn
+		return stun.MustBuild(m, success, id)
+	default:
+		s.t.Errorf("unexpected request %s", m.Type)
+		return stun.MustBuild(m, stun.NewType(m.Type.Method, stun.ClassErrorResponse), stun.CodeBadRequest)
+	}
+}
+
+func (s *tcpServer) acceptPeers(relay net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := relay.Accept()
+		if err != nil {
+			return
+		}
+		peer := conn.RemoteAddr().(*net.TCPAddr)
+		s.mux.Lock()
+		if !s.perms[peer.IP.String()] {
+			s.mux.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		id := s.nextID
+		s.nextID++
+		s.pending[id] = conn
+		control := s.control
+		s.mux.Unlock()
+		m := stun.MustBuild(stun.TransactionID, connectionAttemptIndication,
+			id, stun.XORPeerAddress{IP: peer.IP, Port: peer.Port},
+		)
+		if _, err = control.Write(m.Raw); err != nil {
+			s.t.Error(err)
+		}
+	}
+}
+
+// bind binds data connection to peer connection and relays data between
+// them.
+func (s *tcpServer) bind(conn net.Conn, m *stun.Message) {
+	var id stun.ConnectionID
+	if err := id.GetFrom(m); err != nil {
+		s.t.Error(err)
+	}
+	s.mux.Lock()
+	peer, ok := s.pending[id]
+	delete(s.pending, id)
+	s.mux.Unlock()
+	if !ok {
+		s.t.Errorf("unexpected connection id %d", id)
+		_ = conn.Close()
+		return
+	}
+	res := stun.MustBuild(m, stun.NewType(m.Type.Method, stun.ClassSuccessResponse))
+	if _, err := conn.Write(res.Raw); err != nil {
+		s.t.Error(err)
+	}
+	go func() {
+		_, _ = io.Copy(peer, conn)
+		_ = peer.Close()
+	}()
+	_, _ = io.Copy(conn, peer)
+	_ = conn.Close()
+}
+
+// echo writes message to conn and checks that it is echoed back.
+func echo(t *testing.T, conn net.Conn, message string) {
+	t.Helper()
+	if err := conn.SetDeadline(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte(message)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(message))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != message {
+		t.Errorf("unexpected %q", buf)
+	}
+}
+
+// serveEcho accepts single connection from l and echoes data back.
+func serveEcho(l net.Listener) <-chan net.Addr {
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn.RemoteAddr()
+		_, _ = io.Copy(conn, conn)
+		_ = conn.Close()
+	}()
+	return accepted
+}
+
+func TestTCPClient(t *testing.T) {
+	s := newTCPServer(t)
+	defer s.Close()
+	c, err := AllocateTCP(s.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr().String() != s.relay.Addr().String() {
+		t.Errorf("unexpected relayed address %s", c.Addr())
+	}
+	// No packet conn in TCP allocation.
+	if err = c.client.SetDeadline(time.Time{}); err != nil {
+		t.Error(err)
+	}
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	t.Run("Connect", func(t *testing.T) {
+		accepted := serveEcho(l)
+		conn, err := c.Connect(l.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if conn.RemoteAddr().String() != l.Addr().String() {
+			t.Errorf("unexpected remote address %s", conn.RemoteAddr())
+		}
+		if conn.LocalAddr().String() != c.Addr().String() {
+			t.Errorf("unexpected local address %s", conn.LocalAddr())
+		}
+		echo(t, conn, "hello")
+		if addr := <-accepted; addr == nil {
+			t.Error("peer should accept connection")
+		}
+		s.mux.Lock()
+		if !s.perms["127.0.0.1"] {
+			t.Error("permission should be created")
+		}
+		s.mux.Unlock()
+	})
+	t.Run("Accept", func(t *testing.T) {
+		peer, err := net.Dial("tcp4", c.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer peer.Close()
+		go func() {
+			_, _ = io.Copy(peer, peer)
+		}()
+		conn, err := c.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if conn.RemoteAddr().String() != peer.LocalAddr().String() {
+			t.Errorf("unexpected remote address %s", conn.RemoteAddr())
+		}
+		echo(t, conn, "world")
+	})
+	if err = c.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err = c.Accept(); err != ErrClientClosed {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTCPClient_ConnectError(t *testing.T) {
+	s := newTCPServer(t)
+	defer s.Close()
+	c, err := AllocateTCP(s.dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// Nothing listens on closed listener address.
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr()
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Connect(addr)
+	if resErr, ok := err.(stun.ResponseErr); !ok || resErr.Code.Code != stun.CodeConnTimeoutOrFailure {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err = c.Connect(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}); err == nil {
+		t.Error("should fail on bad address")
+	}
+}
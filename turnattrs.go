@@ -0,0 +1,652 @@
+package stun
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChannelNumber represents CHANNEL-NUMBER attribute.
+//
+// The CHANNEL-NUMBER attribute contains the number of the channel.
+//
+// RFC 5766 Section 14.1
+type ChannelNumber uint16
+
+// Channel number range that can be bound to peer.
+//
+// RFC 5766 Section 11
+const (
+	MinChannelNumber ChannelNumber = 0x4000
+	MaxChannelNumber ChannelNumber = 0x7FFF
+)
+
+const channelNumberSize = 4 // 16 bit number and 16 bit RFFU
+
+// ErrInvalidChannelNumber means that channel number is not in range
+// from MinChannelNumber to MaxChannelNumber.
+var ErrInvalidChannelNumber = errors.New("channel number not in [0x4000, 0x7FFF]")
+
+// Valid reports whether n is in allowed range.
+func (n ChannelNumber) Valid() bool {
+	return n >= MinChannelNumber && n <= MaxChannelNumber
+}
+
+func (n ChannelNumber) String() string { return strconv.Itoa(int(n)) }
+
+// AddTo adds CHANNEL-NUMBER attribute to message.
+func (n ChannelNumber) AddTo(m *Message) error {
+	if !n.Valid() {
+		return ErrInvalidChannelNumber
+	}
+	var v [channelNumberSize]byte
+	bin.PutUint16(v[:2], uint16(n))
+	m.Add(AttrChannelNumber, v[:])
+	return nil
+}
+
+// GetFrom decodes CHANNEL-NUMBER from message.
+func (n *ChannelNumber) GetFrom(m *Message) error {
+	v, err := m.Get(AttrChannelNumber)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrChannelNumber, len(v), channelNumberSize); err != nil {
+		return err
+	}
+	c := ChannelNumber(bin.Uint16(v[:2]))
+	if !c.Valid() {
+		return ErrInvalidChannelNumber
+	}
+	*n = c
+	return nil
+}
+
+// Lifetime represents LIFETIME attribute.
+//
+// The LIFETIME attribute represents the duration for which the server
+// will maintain an allocation in the absence of a refresh. Duration is
+// encoded in seconds.
+//
+// RFC 5766 Section 14.2
+type Lifetime struct {
+	time.Duration
+}
+
+const lifetimeSize = 4 // 32 bit
+
+// AddTo adds LIFETIME attribute to message. Duration is truncated to
+// seconds and clamped to range of 32-bit unsigned integer, so negative
+// duration is encoded as zero.
+func (l Lifetime) AddTo(m *Message) error {
+	seconds := l.Duration / time.Second
+	switch {
+	case seconds < 0:
+		seconds = 0
+	case seconds > math.MaxUint32:
+		seconds = math.MaxUint32
+	}
+	var v [lifetimeSize]byte
+	bin.PutUint32(v[:], uint32(seconds))
+	m.Add(AttrLifetime, v[:])
+	return nil
+}
+
+// GetFrom decodes LIFETIME from message.
+func (l *Lifetime) GetFrom(m *Message) error {
+	v, err := m.Get(AttrLifetime)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrLifetime, len(v), lifetimeSize); err != nil {
+		return err
+	}
+	l.Duration = time.Duration(bin.Uint32(v)) * time.Second
+	return nil
+}
+
+// XORPeerAddress implements XOR-PEER-ADDRESS attribute.
+//
+// The XOR-PEER-ADDRESS specifies the address and port of the peer as seen
+// from the TURN server.
+//
+// RFC 5766 Section 14.3
+type XORPeerAddress struct {
+	IP   net.IP
+	Port int
+}
+
+func (a XORPeerAddress) String() string {
+	return XORMappedAddress(a).String()
+}
+
+// AddTo adds XOR-PEER-ADDRESS to message.
+func (a XORPeerAddress) AddTo(m *Message) error {
+	return XORMappedAddress(a).AddToAs(m, AttrXORPeerAddress)
+}
+
+// GetFrom decodes XOR-PEER-ADDRESS from message.
+func (a *XORPeerAddress) GetFrom(m *Message) error {
+	return (*XORMappedAddress)(a).GetFromAs(m, AttrXORPeerAddress)
+}
+
+// Data represents DATA attribute.
+//
+// The DATA attribute is present in all Send and Data indications.
+//
+// RFC 5766 Section 14.4
+type Data []byte
+
+// AddTo adds DATA to message.
+func (d Data) AddTo(m *Message) error {
+	m.Add(AttrData, d)
+	return nil
+}
+
+// GetFrom decodes DATA from message. Value is valid until m.Raw is valid.
+func (d *Data) GetFrom(m *Message) error {
+	v, err := m.Get(AttrData)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// XORRelayedAddress implements XOR-RELAYED-ADDRESS attribute.
+//
+// The XOR-RELAYED-ADDRESS is present in Allocate responses. It specifies
+// the address and port that the server allocated to the client.
+//
+// RFC 5766 Section 14.5
+type XORRelayedAddress struct {
+	IP   net.IP
+	Port int
+}
+
+func (a XORRelayedAddress) String() string {
+	return XORMappedAddress(a).String()
+}
+
+// AddTo adds XOR-RELAYED-ADDRESS to message.
+func (a XORRelayedAddress) AddTo(m *Message) error {
+	return XORMappedAddress(a).AddToAs(m, AttrXORRelayedAddress)
+}
+
+// GetFrom decodes XOR-RELAYED-ADDRESS from message.
+func (a *XORRelayedAddress) GetFrom(m *Message) error {
+	return (*XORMappedAddress)(a).GetFromAs(m, AttrXORRelayedAddress)
+}
+
+// EvenPort represents EVEN-PORT attribute.
+//
+// This attribute allows the client to request that the port in the
+// relayed transport address be even, and (optionally) that the server
+// reserve the next-higher port number.
+//
+// RFC 5766 Section 14.6
+type EvenPort struct {
+	// ReservePort means that the server is requested to reserve
+	// the next-higher port number (on the same IP address)
+	// for a subsequent allocation.
+	ReservePort bool // flag "R"
+}
+
+const (
+	evenPortSize       = 1
+	evenPortReserveBit = 0x80 // other 7 bits are RFFU
+)
+
+func (p EvenPort) String() string {
+	if p.ReservePort {
+		return "reserve: true"
+	}
+	return "reserve: false"
+}
+
+// AddTo adds EVEN-PORT to message.
+func (p EvenPort) AddTo(m *Message) error {
+	var v [evenPortSize]byte
+	if p.ReservePort {
+		v[0] = evenPortReserveBit
+	}
+	m.Add(AttrEvenPort, v[:])
+	return nil
+}
+
+// GetFrom decodes EVEN-PORT from message, ignoring RFFU bits.
+func (p *EvenPort) GetFrom(m *Message) error {
+	v, err := m.Get(AttrEvenPort)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrEvenPort, len(v), evenPortSize); err != nil {
+		return err
+	}
+	p.ReservePort = v[0]&evenPortReserveBit != 0
+	return nil
+}
+
+// Protocol is IANA assigned protocol number.
+type Protocol byte
+
+// Protocols that can be used as relayed transport.
+const (
+	ProtoTCP Protocol = 6  // RFC 6062
+	ProtoUDP Protocol = 17 // RFC 5766
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtoUDP:
+		return "UDP"
+	case ProtoTCP:
+		return "TCP"
+	default:
+		return strconv.Itoa(int(p))
+	}
+}
+
+// ErrUnsupportedProtocol means that protocol is not UDP or TCP, so
+// Allocate request should be rejected with CodeUnsupportedTransProto.
+var ErrUnsupportedProtocol = errors.New("unsupported transport protocol")
+
+// RequestedTransport represents REQUESTED-TRANSPORT attribute.
+//
+// This attribute is used by the client to request a specific transport
+// protocol for the allocated transport address.
+//
+// RFC 5766 Section 14.7
+type RequestedTransport struct {
+	Protocol Protocol
+}
+
+const requestedTransportSize = 4 // 8 bit protocol and 24 bit RFFU
+
+func (t RequestedTransport) String() string {
+	return "protocol: " + t.Protocol.String()
+}
+
+// AddTo adds REQUESTED-TRANSPORT to message, returning
+// ErrUnsupportedProtocol if protocol is not UDP or TCP.
+func (t RequestedTransport) AddTo(m *Message) error {
+	if t.Protocol != ProtoUDP && t.Protocol != ProtoTCP {
+		return ErrUnsupportedProtocol
+	}
+	var v [requestedTransportSize]byte
+	v[0] = byte(t.Protocol)
+	m.Add(AttrRequestedTransport, v[:])
+	return nil
+}
+
+// GetFrom decodes REQUESTED-TRANSPORT from message. If protocol is not UDP
+// or TCP, ErrUnsupportedProtocol is returned with t.Protocol set, so server
+// can respond with CodeUnsupportedTransProto.
+func (t *RequestedTransport) GetFrom(m *Message) error {
+	v, err := m.Get(AttrRequestedTransport)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrRequestedTransport, len(v), requestedTransportSize); err != nil {
+		return err
+	}
+	t.Protocol = Protocol(v[0])
+	if t.Protocol != ProtoUDP && t.Protocol != ProtoTCP {
+		return ErrUnsupportedProtocol
+	}
+	return nil
+}
+
+// DontFragmentAttr represents DONT-FRAGMENT attribute.
+//
+// This attribute is used by the client to request that the server set
+// the DF (Don't Fragment) bit in the IP header when relaying the
+// application data onward to the peer. The attribute has no value.
+//
+// RFC 5766 Section 14.8
+type DontFragmentAttr struct{}
+
+// DontFragment is shorthand for DontFragmentAttr.
+var DontFragment DontFragmentAttr
+
+// AddTo adds DONT-FRAGMENT attribute to message.
+func (DontFragmentAttr) AddTo(m *Message) error {
+	m.Add(AttrDontFragment, nil)
+	return nil
+}
+
+// GetFrom returns nil if message contains valid DONT-FRAGMENT attribute.
+func (DontFragmentAttr) GetFrom(m *Message) error {
+	v, err := m.Get(AttrDontFragment)
+	if err != nil {
+		return err
+	}
+	return CheckSize(AttrDontFragment, len(v), 0)
+}
+
+// IsSet reports whether message contains DONT-FRAGMENT attribute.
+func (DontFragmentAttr) IsSet(m *Message) bool {
+	return m.Contains(AttrDontFragment)
+}
+
+// ReservationToken represents RESERVATION-TOKEN attribute.
+//
+// The RESERVATION-TOKEN attribute contains a token that uniquely
+// identifies a relayed transport address being held in reserve by the
+// server.
+//
+// RFC 5766 Section 14.9
+type ReservationToken []byte
+
+// ReservationTokenSize is size of RESERVATION-TOKEN value.
+const ReservationTokenSize = 8 // 8 bytes
+
+// AddTo adds RESERVATION-TOKEN to message.
+func (t ReservationToken) AddTo(m *Message) error {
+	if err := CheckSize(AttrReservationToken, len(t), ReservationTokenSize); err != nil {
+		return err
+	}
+	m.Add(AttrReservationToken, t)
+	return nil
+}
+
+// GetFrom decodes RESERVATION-TOKEN from message. Value is valid until
+// m.Raw is valid.
+func (t *ReservationToken) GetFrom(m *Message) error {
+	v, err := m.Get(AttrReservationToken)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrReservationToken, len(v), ReservationTokenSize); err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// RequestedAddressFamily represents REQUESTED-ADDRESS-FAMILY attribute.
+//
+// This attribute is used by clients to request the allocation of a
+// specific address type from a server.
+//
+// RFC 6156 Section 4.1.1
+type RequestedAddressFamily byte
+
+// Values for RequestedAddressFamily.
+const (
+	RequestedFamilyIPv4 RequestedAddressFamily = 0x01
+	RequestedFamilyIPv6 RequestedAddressFamily = 0x02
+)
+
+const requestedFamilySize = 4 // 8 bit family and 24 bit RFFU
+
+// ErrUnsupportedFamily means that address family is not IPv4 or IPv6, so
+// request should be rejected with CodeAddrFamilyNotSupported.
+var ErrUnsupportedFamily = errors.New("unsupported address family")
+
+func (f RequestedAddressFamily) String() string {
+	switch f {
+	case RequestedFamilyIPv4:
+		return "IPv4"
+	case RequestedFamilyIPv6:
+		return "IPv6"
+	default:
+		return "unknown"
+	}
+}
+
+// AddTo adds REQUESTED-ADDRESS-FAMILY to message.
+func (f RequestedAddressFamily) AddTo(m *Message) error {
+	if f != RequestedFamilyIPv4 && f != RequestedFamilyIPv6 {
+		return ErrUnsupportedFamily
+	}
+	var v [requestedFamilySize]byte
+	v[0] = byte(f)
+	m.Add(AttrRequestedAddressFamily, v[:])
+	return nil
+}
+
+// GetFrom decodes REQUESTED-ADDRESS-FAMILY from message. If family is not
+// IPv4 or IPv6, ErrUnsupportedFamily is returned with f set.
+func (f *RequestedAddressFamily) GetFrom(m *Message) error {
+	v, err := m.Get(AttrRequestedAddressFamily)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrRequestedAddressFamily, len(v), requestedFamilySize); err != nil {
+		return err
+	}
+	*f = RequestedAddressFamily(v[0])
+	if *f != RequestedFamilyIPv4 && *f != RequestedFamilyIPv6 {
+		return ErrUnsupportedFamily
+	}
+	return nil
+}
+
+// XORRelayedAddresses represents all XOR-RELAYED-ADDRESS attributes of
+// message. Allocate response for dual-stack allocation contains relayed
+// addresses of both IPv4 and IPv6 families.
+//
+// RFC 8656 Section 7.3
+type XORRelayedAddresses []XORRelayedAddress
+
+func (a XORRelayedAddresses) String() string {
+	s := make([]string, len(a))
+	for i := range a {
+		s[i] = a[i].String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// AddTo adds XOR-RELAYED-ADDRESS for each address to message.
+func (a XORRelayedAddresses) AddTo(m *Message) error {
+	for i := range a {
+		if err := a[i].AddTo(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetFrom decodes all XOR-RELAYED-ADDRESS attributes from message,
+// returning ErrAttributeNotFound if there are none.
+func (a *XORRelayedAddresses) GetFrom(m *Message) error {
+	addrs := (*a)[:0]
+	for _, attr := range m.Attributes {
+		if attr.Type != AttrXORRelayedAddress {
+			continue
+		}
+		var addr XORMappedAddress
+		if err := addr.decode(m, attr.Type, attr.Value); err != nil {
+			return err
+		}
+		addrs = append(addrs, XORRelayedAddress(addr))
+	}
+	*a = addrs
+	if len(addrs) == 0 {
+		return ErrAttributeNotFound
+	}
+	return nil
+}
+
+// AdditionalAddressFamily represents ADDITIONAL-ADDRESS-FAMILY attribute.
+//
+// This attribute is used by clients to request the allocation of both
+// IPv4 and IPv6 relayed addresses, so the only allowed value is
+// RequestedFamilyIPv6.
+//
+// RFC 8656 Section 18.11
+type AdditionalAddressFamily RequestedAddressFamily
+
+func (f AdditionalAddressFamily) String() string {
+	return RequestedAddressFamily(f).String()
+}
+
+// AddTo adds ADDITIONAL-ADDRESS-FAMILY to message.
+func (f AdditionalAddressFamily) AddTo(m *Message) error {
+	if RequestedAddressFamily(f) != RequestedFamilyIPv6 {
+		return ErrUnsupportedFamily
+	}
+	var v [requestedFamilySize]byte
+	v[0] = byte(f)
+	m.Add(AttrAdditionalAddressFamily, v[:])
+	return nil
+}
+
+// GetFrom decodes ADDITIONAL-ADDRESS-FAMILY from message. If family is
+// not IPv6, ErrUnsupportedFamily is returned with f set.
+func (f *AdditionalAddressFamily) GetFrom(m *Message) error {
+	v, err := m.Get(AttrAdditionalAddressFamily)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrAdditionalAddressFamily, len(v), requestedFamilySize); err != nil {
+		return err
+	}
+	*f = AdditionalAddressFamily(v[0])
+	if RequestedAddressFamily(*f) != RequestedFamilyIPv6 {
+		return ErrUnsupportedFamily
+	}
+	return nil
+}
+
+// AddressErrorCode represents ADDRESS-ERROR-CODE attribute.
+//
+// This attribute is used by servers to signal the reason for not
+// allocating relayed address of requested family, e.g. in response to
+// dual-stack allocation.
+//
+// RFC 8656 Section 18.12
+type AddressErrorCode struct {
+	Family RequestedAddressFamily
+	Code   ErrorCode
+	Reason []byte
+}
+
+const addressErrorCodeFamilyByte = 0
+
+func (c AddressErrorCode) String() string {
+	return fmt.Sprintf("%s: %d: %s", c.Family, c.Code, c.Reason)
+}
+
+// AddTo adds ADDRESS-ERROR-CODE to message.
+func (c AddressErrorCode) AddTo(m *Message) error {
+	if c.Family != RequestedFamilyIPv4 && c.Family != RequestedFamilyIPv6 {
+		return ErrUnsupportedFamily
+	}
+	if err := CheckOverflow(AttrAddressErrorCode,
+		len(c.Reason)+errorCodeReasonStart,
+		errorCodeReasonMaxB+errorCodeReasonStart,
+	); err != nil {
+		return err
+	}
+	v := make([]byte, errorCodeReasonStart+len(c.Reason))
+	v[addressErrorCodeFamilyByte] = byte(c.Family)
+	v[errorCodeClassByte] = byte(c.Code / errorCodeModulo)
+	v[errorCodeNumberByte] = byte(c.Code % errorCodeModulo)
+	copy(v[errorCodeReasonStart:], c.Reason)
+	m.Add(AttrAddressErrorCode, v)
+	return nil
+}
+
+// GetFrom decodes ADDRESS-ERROR-CODE from message. Reason is valid until
+// m.Raw is valid.
+func (c *AddressErrorCode) GetFrom(m *Message) error {
+	v, err := m.Get(AttrAddressErrorCode)
+	if err != nil {
+		return err
+	}
+	if len(v) < errorCodeReasonStart {
+		return io.ErrUnexpectedEOF
+	}
+	var (
+		class  = int(v[errorCodeClassByte])
+		number = int(v[errorCodeNumberByte])
+	)
+	c.Family = RequestedAddressFamily(v[addressErrorCodeFamilyByte])
+	c.Code = ErrorCode(class*errorCodeModulo + number)
+	c.Reason = v[errorCodeReasonStart:]
+	if c.Family != RequestedFamilyIPv4 && c.Family != RequestedFamilyIPv6 {
+		return ErrUnsupportedFamily
+	}
+	return nil
+}
+
+// ICMP represents ICMP attribute.
+//
+// This attribute is used by servers to signal the reason a UDP packet
+// was dropped, it is included in Data indication.
+//
+// RFC 8656 Section 18.13
+type ICMP struct {
+	Type byte   // ICMP type, interpretation depends on IP version
+	Code byte   // ICMP code, interpretation depends on IP version
+	Data uint32 // error data, e.g. MTU for "packet too big"
+}
+
+const icmpSize = 8 // 16 bit RFFU, 8 bit type, 8 bit code, 32 bit data
+
+func (i ICMP) String() string {
+	return fmt.Sprintf("type: %d, code: %d, data: %d", i.Type, i.Code, i.Data)
+}
+
+// AddTo adds ICMP to message.
+func (i ICMP) AddTo(m *Message) error {
+	var v [icmpSize]byte
+	v[2] = i.Type
+	v[3] = i.Code
+	bin.PutUint32(v[4:], i.Data)
+	m.Add(AttrICMP, v[:])
+	return nil
+}
+
+// GetFrom decodes ICMP from message.
+func (i *ICMP) GetFrom(m *Message) error {
+	v, err := m.Get(AttrICMP)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrICMP, len(v), icmpSize); err != nil {
+		return err
+	}
+	i.Type = v[2]
+	i.Code = v[3]
+	i.Data = bin.Uint32(v[4:])
+	return nil
+}
+
+// ConnectionID represents CONNECTION-ID attribute.
+//
+// The CONNECTION-ID attribute uniquely identifies a peer data
+// connection.
+//
+// RFC 6062 Section 6.2.1
+type ConnectionID uint32
+
+const connectionIDSize = 4 // 32 bit
+
+// AddTo adds CONNECTION-ID to message.
+func (c ConnectionID) AddTo(m *Message) error {
+	var v [connectionIDSize]byte
+	bin.PutUint32(v[:], uint32(c))
+	m.Add(AttrConnectionID, v[:])
+	return nil
+}
+
+// GetFrom decodes CONNECTION-ID from message.
+func (c *ConnectionID) GetFrom(m *Message) error {
+	v, err := m.Get(AttrConnectionID)
+	if err != nil {
+		return err
+	}
+	if err = CheckSize(AttrConnectionID, len(v), connectionIDSize); err != nil {
+		return err
+	}
+	*c = ConnectionID(bin.Uint32(v))
+	return nil
+}
@@ -0,0 +1,541 @@
+package stun
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChannelNumber(t *testing.T) {
+	m := new(Message)
+	n := ChannelNumber(0x4001)
+	if err := n.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Get(AttrChannelNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{0x40, 0x01, 0, 0}) {
+		t.Errorf("unexpected value %x", v)
+	}
+	var got ChannelNumber
+	if err = got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != n {
+		t.Errorf("%s (got) != %s (expected)", got, n)
+	}
+	t.Run("Invalid", func(t *testing.T) {
+		for _, n := range []ChannelNumber{0, MinChannelNumber - 1, MaxChannelNumber + 1} {
+			if err := n.AddTo(new(Message)); err != ErrInvalidChannelNumber {
+				t.Errorf("%s: unexpected error %v", n, err)
+			}
+			m := new(Message)
+			m.Add(AttrChannelNumber, []byte{byte(n >> 8), byte(n), 0, 0})
+			if err := got.GetFrom(m); err != ErrInvalidChannelNumber {
+				t.Errorf("%s: unexpected error %v", n, err)
+			}
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrChannelNumber, []byte{0x40, 0x01})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+	t.Run("Not found", func(t *testing.T) {
+		if err := got.GetFrom(new(Message)); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+}
+
+func TestLifetime(t *testing.T) {
+	m := new(Message)
+	l := Lifetime{Duration: time.Minute * 10}
+	if err := l.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Get(AttrLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{0, 0, 0x02, 0x58}) {
+		t.Errorf("unexpected value %x", v)
+	}
+	var got Lifetime
+	if err = got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != l {
+		t.Errorf("%s (got) != %s (expected)", got, l)
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrLifetime, []byte{1, 2, 3})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+	t.Run("Clamp", func(t *testing.T) {
+		for _, tc := range []struct {
+			in  time.Duration
+			out []byte
+		}{
+			{in: -time.Second, out: []byte{0, 0, 0, 0}},
+			{in: time.Duration(math.MinInt64), out: []byte{0, 0, 0, 0}},
+			{in: time.Second*math.MaxUint32 + time.Second - 1, out: []byte{0xff, 0xff, 0xff, 0xff}},
+			{in: time.Second * (math.MaxUint32 + 1), out: []byte{0xff, 0xff, 0xff, 0xff}},
+			{in: time.Duration(math.MaxInt64), out: []byte{0xff, 0xff, 0xff, 0xff}},
+		} {
+			m := new(Message)
+			if err := (Lifetime{Duration: tc.in}).AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			v, err := m.Get(AttrLifetime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(v, tc.out) {
+				t.Errorf("%s: unexpected value %x", tc.in, v)
+			}
+		}
+	})
+}
+
+func TestXORPeerAddress(t *testing.T) {
+	m := new(Message)
+	m.TransactionID = [TransactionIDSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	a := XORPeerAddress{IP: net.ParseIP("2001:db8::1"), Port: 3478}
+	if err := a.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got XORPeerAddress
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != a.String() {
+		t.Errorf("%s (got) != %s (expected)", got, a)
+	}
+	if m.Contains(AttrXORMappedAddress) {
+		t.Error("should not be added as XOR-MAPPED-ADDRESS")
+	}
+	if err := new(XORRelayedAddress).GetFrom(m); err != ErrAttributeNotFound {
+		t.Error("should be not found: ", err)
+	}
+}
+
+func TestXORRelayedAddress(t *testing.T) {
+	m := new(Message)
+	a := XORRelayedAddress{IP: net.IPv4(192, 0, 2, 15), Port: 50000}
+	if err := a.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got XORRelayedAddress
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "192.0.2.15:50000" {
+		t.Errorf("unexpected address %s", got)
+	}
+	if err := (XORRelayedAddress{IP: net.IP{1, 2}}).AddTo(m); err != ErrBadIPLength {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestData(t *testing.T) {
+	m := new(Message)
+	d := Data{1, 2, 3, 4, 5}
+	if err := d.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got Data
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, d) {
+		t.Errorf("%x (got) != %x (expected)", got, d)
+	}
+	if err := got.GetFrom(new(Message)); err != ErrAttributeNotFound {
+		t.Error("should be not found: ", err)
+	}
+}
+
+func TestEvenPort(t *testing.T) {
+	for _, p := range []EvenPort{{}, {ReservePort: true}} {
+		t.Run(p.String(), func(t *testing.T) {
+			m := new(Message)
+			if err := p.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			var got EvenPort
+			if err := got.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if got != p {
+				t.Errorf("%s (got) != %s (expected)", got, p)
+			}
+		})
+	}
+	t.Run("RFFU", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrEvenPort, []byte{0x7F})
+		var got EvenPort
+		if err := got.GetFrom(m); err != nil {
+			t.Fatal(err)
+		}
+		if got.ReservePort {
+			t.Error("RFFU bits should be ignored")
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrEvenPort, []byte{0x80, 0, 0, 0})
+		if err := new(EvenPort).GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestRequestedTransport(t *testing.T) {
+	for _, p := range []Protocol{ProtoUDP, ProtoTCP} {
+		t.Run(p.String(), func(t *testing.T) {
+			m := new(Message)
+			r := RequestedTransport{Protocol: p}
+			if err := r.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			v, err := m.Get(AttrRequestedTransport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(v, []byte{byte(p), 0, 0, 0}) {
+				t.Errorf("unexpected value %x", v)
+			}
+			var got RequestedTransport
+			if err = got.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if got != r {
+				t.Errorf("%s (got) != %s (expected)", got, r)
+			}
+		})
+	}
+	t.Run("Unsupported", func(t *testing.T) {
+		r := RequestedTransport{Protocol: 132}
+		if r.String() != "protocol: 132" {
+			t.Errorf("unexpected string %q", r)
+		}
+		if err := r.AddTo(new(Message)); err != ErrUnsupportedProtocol {
+			t.Errorf("unexpected error %v", err)
+		}
+		m := new(Message)
+		m.Add(AttrRequestedTransport, []byte{132, 0, 0, 0})
+		var got RequestedTransport
+		if err := got.GetFrom(m); err != ErrUnsupportedProtocol {
+			t.Errorf("unexpected error %v", err)
+		}
+		if got.Protocol != 132 {
+			t.Error("protocol should be set")
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrRequestedTransport, []byte{17})
+		if err := new(RequestedTransport).GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestDontFragment(t *testing.T) {
+	m := new(Message)
+	if DontFragment.IsSet(m) {
+		t.Error("should not be set")
+	}
+	if err := DontFragment.GetFrom(m); err != ErrAttributeNotFound {
+		t.Error("should be not found: ", err)
+	}
+	if err := DontFragment.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	if !DontFragment.IsSet(m) {
+		t.Error("should be set")
+	}
+	if err := DontFragment.GetFrom(m); err != nil {
+		t.Error(err)
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrDontFragment, []byte{1})
+		if err := DontFragment.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestReservationToken(t *testing.T) {
+	m := new(Message)
+	tok := ReservationToken{1, 2, 3, 4, 5, 6, 7, 8}
+	if err := tok.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got ReservationToken
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, tok) {
+		t.Errorf("%x (got) != %x (expected)", got, tok)
+	}
+	if err := ReservationToken(tok[:4]).AddTo(m); !IsAttrSizeInvalid(err) {
+		t.Error("should be invalid size: ", err)
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrReservationToken, []byte{1, 2, 3})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestRequestedAddressFamily(t *testing.T) {
+	for _, f := range []RequestedAddressFamily{RequestedFamilyIPv4, RequestedFamilyIPv6} {
+		t.Run(f.String(), func(t *testing.T) {
+			m := new(Message)
+			if err := f.AddTo(m); err != nil {
+				t.Fatal(err)
+			}
+			var got RequestedAddressFamily
+			if err := got.GetFrom(m); err != nil {
+				t.Fatal(err)
+			}
+			if got != f {
+				t.Errorf("%s (got) != %s (expected)", got, f)
+			}
+		})
+	}
+	t.Run("Unsupported", func(t *testing.T) {
+		f := RequestedAddressFamily(3)
+		if f.String() != "unknown" {
+			t.Errorf("unexpected string %q", f)
+		}
+		if err := f.AddTo(new(Message)); err != ErrUnsupportedFamily {
+			t.Errorf("unexpected error %v", err)
+		}
+		m := new(Message)
+		m.Add(AttrRequestedAddressFamily, []byte{3, 0, 0, 0})
+		var got RequestedAddressFamily
+		if err := got.GetFrom(m); err != ErrUnsupportedFamily {
+			t.Errorf("unexpected error %v", err)
+		}
+		if got != f {
+			t.Error("family should be set")
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrRequestedAddressFamily, []byte{1, 0})
+		if err := new(RequestedAddressFamily).GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestConnectionID(t *testing.T) {
+	m := new(Message)
+	c := ConnectionID(0xdeadbeef)
+	if err := c.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got ConnectionID
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Errorf("%x (got) != %x (expected)", got, c)
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrConnectionID, []byte{1, 2})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestXORRelayedAddresses(t *testing.T) {
+	m := new(Message)
+	m.TransactionID = [TransactionIDSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	addrs := XORRelayedAddresses{
+		{IP: net.IPv4(122, 12, 34, 5), Port: 5412},
+		{IP: net.ParseIP("2001:db8::1"), Port: 5413},
+	}
+	if err := addrs.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	var got XORRelayedAddresses
+	if err := got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(addrs) {
+		t.Fatalf("unexpected addresses %s", got)
+	}
+	for i := range addrs {
+		if !got[i].IP.Equal(addrs[i].IP) || got[i].Port != addrs[i].Port {
+			t.Errorf("%s (got) != %s (expected)", got[i], addrs[i])
+		}
+	}
+	if got.String() != "122.12.34.5:5412, [2001:db8::1]:5413" {
+		t.Errorf("unexpected string %q", got)
+	}
+	t.Run("Not found", func(t *testing.T) {
+		if err := got.GetFrom(new(Message)); err != ErrAttributeNotFound {
+			t.Error("should be not found: ", err)
+		}
+	})
+	t.Run("Bad family", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrXORRelayedAddress, []byte{0, 5, 0, 1, 1, 2, 3, 4})
+		if err := got.GetFrom(m); err == nil {
+			t.Error("should error")
+		}
+	})
+}
+
+func TestAdditionalAddressFamily(t *testing.T) {
+	m := new(Message)
+	f := AdditionalAddressFamily(RequestedFamilyIPv6)
+	if err := f.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Get(AttrAdditionalAddressFamily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{0x02, 0, 0, 0}) {
+		t.Errorf("unexpected value %x", v)
+	}
+	var got AdditionalAddressFamily
+	if err = got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != f {
+		t.Errorf("%s (got) != %s (expected)", got, f)
+	}
+	t.Run("Unsupported", func(t *testing.T) {
+		if err := AdditionalAddressFamily(RequestedFamilyIPv4).AddTo(new(Message)); err != ErrUnsupportedFamily {
+			t.Errorf("unexpected error %v", err)
+		}
+		m := new(Message)
+		m.Add(AttrAdditionalAddressFamily, []byte{0x01, 0, 0, 0})
+		if err := got.GetFrom(m); err != ErrUnsupportedFamily {
+			t.Errorf("unexpected error %v", err)
+		}
+		if RequestedAddressFamily(got) != RequestedFamilyIPv4 {
+			t.Errorf("unexpected family %s", got)
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrAdditionalAddressFamily, []byte{0x02})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func TestAddressErrorCode(t *testing.T) {
+	m := new(Message)
+	c := AddressErrorCode{
+		Family: RequestedFamilyIPv6,
+		Code:   CodeAddrFamilyNotSupported,
+		Reason: []byte("Address Family not Supported"),
+	}
+	if err := c.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Get(AttrAddressErrorCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v[:4], []byte{0x02, 0, 4, 40}) {
+		t.Errorf("unexpected value %x", v)
+	}
+	var got AddressErrorCode
+	if err = got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got.Family != c.Family || got.Code != c.Code || !bytes.Equal(got.Reason, c.Reason) {
+		t.Errorf("%s (got) != %s (expected)", got, c)
+	}
+	t.Run("Unsupported", func(t *testing.T) {
+		if err := (AddressErrorCode{Family: 3}).AddTo(new(Message)); err != ErrUnsupportedFamily {
+			t.Errorf("unexpected error %v", err)
+		}
+		m := new(Message)
+		m.Add(AttrAddressErrorCode, []byte{0x03, 0, 4, 40})
+		if err := got.GetFrom(m); err != ErrUnsupportedFamily {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+	t.Run("Overflow", func(t *testing.T) {
+		c := AddressErrorCode{Family: RequestedFamilyIPv4, Reason: make([]byte, 1024)}
+		if err := c.AddTo(new(Message)); !IsAttrSizeOverflow(err) {
+			t.Error("should overflow: ", err)
+		}
+	})
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrAddressErrorCode, []byte{0x01, 0})
+		if err := got.GetFrom(m); err != io.ErrUnexpectedEOF {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestICMP(t *testing.T) {
+	m := new(Message)
+	i := ICMP{Type: 3, Code: 4, Data: 1400}
+	if err := i.AddTo(m); err != nil {
+		t.Fatal(err)
+	}
+	v, err := m.Get(AttrICMP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{0, 0, 3, 4, 0, 0, 0x05, 0x78}) {
+		t.Errorf("unexpected value %x", v)
+	}
+	var got ICMP
+	if err = got.GetFrom(m); err != nil {
+		t.Fatal(err)
+	}
+	if got != i {
+		t.Errorf("%s (got) != %s (expected)", got, i)
+	}
+	t.Run("Bad length", func(t *testing.T) {
+		m := new(Message)
+		m.Add(AttrICMP, []byte{0, 0, 3, 4})
+		if err := got.GetFrom(m); !IsAttrSizeInvalid(err) {
+			t.Error("should be invalid size: ", err)
+		}
+	})
+}
+
+func BenchmarkChannelNumber_AddTo(b *testing.B) {
+	b.ReportAllocs()
+	m := new(Message)
+	n := ChannelNumber(0x4001)
+	for i := 0; i < b.N; i++ {
+		if err := n.AddTo(m); err != nil {
+			b.Fatal(err)
+		}
+		m.Reset()
+	}
+}
@@ -84,6 +84,11 @@ func (a *XORMappedAddress) GetFromAs(m *Message, t AttrType) error {
 	if err != nil {
 		return err
 	}
+	return a.decode(m, t, v)
+}
+
+// decode decodes value v of attribute with type t from message m.
+func (a *XORMappedAddress) decode(m *Message, t AttrType, v []byte) error {
 	family := bin.Uint16(v[0:2])
 	if family != familyIPv6 && family != familyIPv4 {
 		return newDecodeErr("xor-mapped address", "family",